@@ -0,0 +1,153 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mh-cbon/stringexec"
+	"github.com/stirante/go-msi/templates"
+	"github.com/stirante/go-msi/util"
+)
+
+// ChocoOptions configures a chocolatey package build,
+// the Manifest, Templates, Out, Version, Compression, Keep and output
+// fields of Options are used.
+type ChocoOptions struct {
+	Options
+	Input        string // path to the msi file to package
+	ChangelogCmd string // command printing the changelog of the package
+	Nupkg        string // path of the package to write, named after the package id and version when empty
+}
+
+// ChocoResult describes a built chocolatey package.
+type ChocoResult struct {
+	Nupkg    string `json:"nupkg"` // path to the nupkg file
+	ID       string `json:"id"`
+	Version  string `json:"version"`
+	SHA256   string `json:"sha256"`
+	MsiSum   string `json:"msi-sha256"`          // sha256 of the packaged msi file
+	BuildDir string `json:"build-dir,omitempty"` // the build directory, when kept
+}
+
+// Choco builds a chocolatey package of the msi file opts.Input.
+func Choco(ctx context.Context, opts ChocoOptions) (result *ChocoResult, err error) {
+	if opts.Input == "" {
+		return nil, fmt.Errorf("the input msi file path must be set")
+	}
+	wixFile, err := opts.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := opts.defaults(); err != nil {
+		return nil, err
+	}
+	out := opts.Out
+	defer removeBuildDir(out, opts.Keep, &err)
+	if err := os.RemoveAll(out); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(out, 0744); err != nil {
+		return nil, err
+	}
+
+	if err := wixFile.Normalize(); err != nil {
+		return nil, err
+	}
+
+	tpls, err := templates.Find(opts.Templates, "*")
+	if err != nil {
+		return nil, err
+	}
+	if len(tpls) == 0 {
+		return nil, fmt.Errorf("No templates found in this directory")
+	}
+
+	out, err = filepath.Abs(out)
+	if err != nil {
+		return nil, err
+	}
+	wixFile.Choco.BuildDir = out
+	wixFile.Choco.MsiFile = filepath.Base(opts.Input)
+	wixFile.Choco.MsiSum, err = util.ComputeSha256(opts.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ChangelogCmd != "" {
+		windows, err := stringexec.Command(opts.ChangelogCmd)
+		if err != nil {
+			return nil, err
+		}
+		windows.Stderr = opts.Stderr
+		out, err := windows.Output()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to execute command to generate the changelog:%q\n%v", opts.ChangelogCmd, err.Error())
+		}
+		sout := string(out)
+		souts := strings.Split(sout, "\n")
+		if len(souts) > 2 {
+			souts = souts[2:] // why ? command line artifacts ? todo: put an explanation here.
+		}
+		sout = strings.Join(souts, "\n")
+
+		wixFile.Choco.ChangeLog = sout
+	}
+
+	if err := util.CopyFile(filepath.Join(wixFile.Choco.BuildDir, wixFile.Choco.MsiFile), opts.Input); err != nil {
+		return nil, err
+	}
+
+	for _, tpl := range tpls {
+		dst := filepath.Join(out, filepath.Base(tpl))
+		if _, err := templates.GenerateTemplate(wixFile, tpl, dst); err != nil {
+			return nil, err
+		}
+	}
+
+	bin, err := exec.LookPath("choco")
+	if err != nil {
+		return nil, err
+	}
+	oCmd := exec.CommandContext(ctx, bin, "pack")
+	oCmd.Dir = out
+	oCmd.Stdout = opts.Stdout
+	oCmd.Stderr = opts.Stderr
+	if err := oCmd.Run(); err != nil {
+		return nil, err
+	}
+
+	srcNupkg := filepath.Join(out, fmt.Sprintf("%s.%s.nupkg", wixFile.Choco.ID, wixFile.Version.MSI))
+	dstNupkg := opts.Nupkg
+	if dstNupkg == "" {
+		dstNupkg = fmt.Sprintf("%s.%s.nupkg", wixFile.Choco.ID, wixFile.Version.User)
+	}
+
+	if err := util.CopyFile(dstNupkg, srcNupkg); err != nil {
+		return nil, err
+	}
+	opts.Logger.Printf("Package copied to %s", dstNupkg)
+
+	result = &ChocoResult{
+		ID:      wixFile.Choco.ID,
+		Version: wixFile.Version.User,
+		MsiSum:  wixFile.Choco.MsiSum,
+	}
+	if result.Nupkg, err = filepath.Abs(dstNupkg); err != nil {
+		return nil, err
+	}
+	if result.SHA256, err = util.ComputeSha256(dstNupkg); err != nil {
+		return nil, err
+	}
+	if err := cleanBuildDir(out, opts.Keep, opts.Logger); err != nil {
+		return nil, err
+	}
+	if opts.Keep {
+		result.BuildDir = out
+	}
+	return result, nil
+}
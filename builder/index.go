@@ -0,0 +1,386 @@
+// Package builder builds msi packages and chocolatey packages
+// out of a wix manifest, it is the library behind the go-msi commands.
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/stirante/go-msi/manifest"
+	"github.com/stirante/go-msi/msidb"
+	"github.com/stirante/go-msi/native"
+	"github.com/stirante/go-msi/rtf"
+	"github.com/stirante/go-msi/templates"
+	"github.com/stirante/go-msi/util"
+	"github.com/stirante/go-msi/wix"
+)
+
+// Logger receives the progress messages of a build.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggerFunc adapts a function to the Logger interface.
+type LoggerFunc func(format string, v ...interface{})
+
+// Printf calls f.
+func (f LoggerFunc) Printf(format string, v ...interface{}) { f(format, v...) }
+
+// NewLogger returns a Logger writing a line per message to w.
+func NewLogger(w io.Writer) Logger {
+	return LoggerFunc(func(format string, v ...interface{}) {
+		fmt.Fprintf(w, strings.TrimSuffix(format, "\n")+"\n", v...)
+	})
+}
+
+// Discard is a Logger ignoring all messages.
+var Discard Logger = LoggerFunc(func(string, ...interface{}) {})
+
+// Options configures a build.
+type Options struct {
+	Manifest    string   // path to the wix manifest, the first of manifest.Filenames found when empty
+	Templates   string   // directory of the wix templates
+	Out         string   // build directory, a temporary directory when empty
+	MSI         string   // path of the msi file to write
+	Arch        string   // target architecture, amd64 or 386
+	Version     string   // version of the program
+	Display     string   // display version of the program
+	License     string   // path to the license file, overrides the manifest
+	Compression string   // cabinet compression level, overrides the manifest
+	Properties  []string // properties to set, defined as Id=Value
+	Backend     string   // wix (default), wixl or native
+	Bin         string   // directory of the toolchain binaries, if not in PATH
+	Keep        bool     // keep the build directory
+	WixlDrop    bool     // let wixl drop the service recovery, permissions, folder removals and hooks
+
+	Stdout io.Writer // output of the toolchain commands, discarded when nil
+	Stderr io.Writer
+	Logger Logger // discards the messages when nil
+}
+
+// Result describes a built msi package.
+type Result struct {
+	MSI         string       `json:"msi"` // absolute path to the msi file
+	ProductCode string       `json:"product-code"`
+	UpgradeCode string       `json:"upgrade-code"`
+	Version     string       `json:"version"` // the msi product version
+	SHA256      string       `json:"sha256"`
+	Size        int64        `json:"size"`
+	BuildDir    string       `json:"build-dir,omitempty"` // the build directory, when kept
+	Templates   []string     `json:"templates,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	Warnings    []string     `json:"warnings,omitempty"`
+}
+
+// Diagnostic is a toolchain diagnostic
+// and the manifest fields which produced the faulty element.
+type Diagnostic struct {
+	wix.Diagnostic
+	Fields []string `json:"fields,omitempty"`
+}
+
+// ToolchainError reports a failed toolchain command and its diagnostics.
+type ToolchainError struct {
+	Err         error
+	Diagnostics []Diagnostic
+}
+
+func (e *ToolchainError) Error() string {
+	return e.Err.Error()
+}
+
+// Backends lists the supported backends.
+var Backends = []string{"wix", "wixl", "native"}
+
+func (opts *Options) defaults() error {
+	if opts.Backend == "" {
+		opts.Backend = "wix"
+	}
+	valid := false
+	for _, b := range Backends {
+		valid = valid || b == opts.Backend
+	}
+	if !valid {
+		return fmt.Errorf("invalid backend %q, must be one of %s", opts.Backend, strings.Join(Backends, ", "))
+	}
+	if opts.Logger == nil {
+		opts.Logger = Discard
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = ioutil.Discard
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = ioutil.Discard
+	}
+	if opts.Out == "" {
+		out, err := ioutil.TempDir("", "go-msi")
+		if err != nil {
+			return err
+		}
+		opts.Out = out
+	}
+	return nil
+}
+
+// load reads the manifest and applies the options to it.
+func (opts *Options) load() (*manifest.WixManifest, error) {
+	wixFile := &manifest.WixManifest{}
+	if err := wixFile.Load(opts.Manifest); err != nil {
+		return nil, err
+	}
+	if opts.Compression != "" {
+		wixFile.Compression = opts.Compression
+	}
+	wixFile.Version.User = opts.Version
+	wixFile.Version.Display = opts.Display
+	if opts.License != "" {
+		wixFile.License = opts.License
+	}
+	if err := addProperties(wixFile, opts.Properties); err != nil {
+		return nil, err
+	}
+	return wixFile, nil
+}
+
+// Build builds the msi package described by opts.
+func Build(ctx context.Context, opts Options) (result *Result, err error) {
+	if opts.MSI == "" {
+		return nil, fmt.Errorf("the msi file path must be set")
+	}
+	wixFile, err := opts.load()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := wixFile.SetGuids(false); err != nil {
+		return nil, err
+	}
+
+	if err := opts.defaults(); err != nil {
+		return nil, err
+	}
+	out := opts.Out
+	defer removeBuildDir(out, opts.Keep, &err)
+	if err := os.RemoveAll(out); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(out, 0744); err != nil {
+		return nil, err
+	}
+
+	if wixFile.License != "" && opts.Backend == "wix" {
+		isRtf, err := rtf.IsRtf(wixFile.License)
+		if err != nil {
+			return nil, err
+		}
+		if !isRtf {
+			opts.Logger.Printf("Converting license to RTF")
+			target := filepath.Join(out, filepath.Base(wixFile.License)+".rtf")
+			if err := rtf.WriteAsRtf(wixFile.License, target, true); err != nil {
+				return nil, err
+			}
+			wixFile.License = target
+		}
+	}
+
+	if err := wixFile.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if err := wixFile.RewriteFilePaths(out); err != nil {
+		return nil, err
+	}
+
+	result = &Result{UpgradeCode: wixFile.UpgradeCode, Version: wixFile.Version.MSI}
+	if opts.Backend == "native" {
+		if err := native.Make(wixFile, out, opts.Arch, opts.MSI); err != nil {
+			return nil, err
+		}
+	} else if err := opts.compile(ctx, wixFile, result); err != nil {
+		return nil, err
+	}
+
+	if err := result.describe(opts.MSI); err != nil {
+		return nil, err
+	}
+	if err := cleanBuildDir(out, opts.Keep, opts.Logger); err != nil {
+		return nil, err
+	}
+	if opts.Keep {
+		result.BuildDir = out
+	}
+	return result, nil
+}
+
+// compile generates the templates and runs the toolchain on them.
+func (opts *Options) compile(ctx context.Context, wixFile *manifest.WixManifest, result *Result) error {
+	out := opts.Out
+	tpls, err := templates.Find(opts.Templates, "*.wxs")
+	if err != nil {
+		return err
+	}
+	if len(tpls) == 0 {
+		return fmt.Errorf("No templates *.wxs found in this directory")
+	}
+
+	builtTemplates := make([]string, len(tpls))
+	sourceMaps := make(map[string]*templates.SourceMap)
+	for i, tpl := range tpls {
+		dst := filepath.Join(out, filepath.Base(tpl))
+		sourceMaps[filepath.Base(dst)], err = templates.GenerateTemplate(wixFile, tpl, dst)
+		builtTemplates[i] = dst
+		if err != nil {
+			return err
+		}
+	}
+	result.Templates = builtTemplates
+
+	msi, err := filepath.Abs(opts.MSI)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	msi, err = filepath.Rel(abs, msi)
+	if err != nil {
+		return err
+	}
+
+	bin := opts.Bin
+	if bin != "" {
+		if bin, err = filepath.Abs(bin); err != nil {
+			return err
+		}
+	}
+
+	toolchain, err := wix.Find(opts.Backend)
+	if err != nil {
+		return err
+	}
+	if w, ok := toolchain.(wix.Wixl); ok {
+		w.Drop = opts.WixlDrop
+		toolchain = w
+	}
+
+	sources, notes, err := toolchain.Prepare(builtTemplates)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		opts.Logger.Printf("%s: %s", toolchain.Name(), note)
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%s: %s", toolchain.Name(), note))
+	}
+
+	// the lines of stdout and stderr are annotated concurrently
+	var mu sync.Mutex
+	annotate := func(line string) string {
+		d, ok := wix.ParseDiagnostic(line)
+		if !ok {
+			return line
+		}
+		file := filepath.Base(filepath.FromSlash(strings.Replace(d.File, `\`, "/", -1)))
+		diagnostic := Diagnostic{Diagnostic: d, Fields: sourceMaps[file].Lookup(d.Line, d.Message)}
+		mu.Lock()
+		result.Diagnostics = append(result.Diagnostics, diagnostic)
+		mu.Unlock()
+		if len(diagnostic.Fields) == 0 {
+			return line
+		}
+		fields := diagnostic.Fields
+		if len(fields) > 3 {
+			fields = fields[:3]
+		}
+		return fmt.Sprintf("%s\n    in %s: %s", line, wixFile.Source(), strings.Join(fields, ", "))
+	}
+	stdout := wix.NewLineWriter(opts.Stdout, annotate)
+	stderr := wix.NewLineWriter(opts.Stderr, annotate)
+	runner := &wix.Runner{Dir: out, Stdout: stdout, Stderr: stderr}
+	err = runner.Run(ctx, toolchain.Commands(sources, msi, opts.Arch, bin))
+	stdout.Flush()
+	stderr.Flush()
+	for _, d := range result.Diagnostics {
+		if d.Severity == "warning" {
+			result.Warnings = append(result.Warnings, d.Code+": "+d.Message)
+		}
+	}
+	if err != nil {
+		return &ToolchainError{Err: err, Diagnostics: result.Diagnostics}
+	}
+	return nil
+}
+
+// describe fills the result with the properties of the msi file.
+func (result *Result) describe(msi string) error {
+	var err error
+	if result.MSI, err = filepath.Abs(msi); err != nil {
+		return err
+	}
+	info, err := os.Stat(result.MSI)
+	if err != nil {
+		return err
+	}
+	result.Size = info.Size()
+	if result.SHA256, err = util.ComputeSha256(result.MSI); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(result.MSI)
+	if err != nil {
+		return err
+	}
+	db, err := msidb.Read(data)
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("cannot read the product code: %v", err))
+		return nil
+	}
+	for _, t := range db.Tables {
+		if t.Name != "Property" {
+			continue
+		}
+		for _, row := range t.Rows {
+			if row[0] == "ProductCode" {
+				result.ProductCode, _ = row[1].(string)
+			}
+		}
+	}
+	return nil
+}
+
+// removeBuildDir removes the build directory when the build failed,
+// unless it is kept for debug.
+func removeBuildDir(out string, keep bool, err *error) {
+	if *err != nil && !keep {
+		os.RemoveAll(out)
+	}
+}
+
+func cleanBuildDir(out string, keep bool, logger Logger) error {
+	if !keep {
+		return os.RemoveAll(out)
+	}
+	logger.Printf("Build files are available in %s", out)
+	return nil
+}
+
+func addProperties(wixFile *manifest.WixManifest, properties []string) error {
+	for _, prop := range properties {
+		s := strings.SplitN(prop, "=", 2)
+		if len(s) < 2 {
+			return fmt.Errorf("property definition must be of the form Id=Value")
+		}
+		v := manifest.Value(s[1])
+		wixFile.Properties = append(wixFile.Properties,
+			manifest.Property{
+				ID:    s[0],
+				Value: &v,
+			},
+		)
+	}
+	return nil
+}
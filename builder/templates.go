@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/stirante/go-msi/templates"
+)
+
+// GenerateTemplates generates the wix templates of opts.Templates into
+// opts.Out, it returns the paths of the generated files.
+func GenerateTemplates(opts Options) ([]string, error) {
+	if opts.Out == "" {
+		return nil, fmt.Errorf("the output directory must be set")
+	}
+	if err := opts.defaults(); err != nil {
+		return nil, err
+	}
+	out := opts.Out
+
+	wixFile, err := opts.load()
+	if err != nil {
+		return nil, err
+	}
+	if wixFile.NeedGUID() {
+		return nil, fmt.Errorf(`The manifest needs Guid, To update your file automatically run "go-msi set-guid"`)
+	}
+
+	if err := wixFile.Normalize(); err != nil {
+		return nil, err
+	}
+
+	if err := wixFile.RewriteFilePaths(out); err != nil {
+		return nil, err
+	}
+
+	tpls, err := templates.Find(opts.Templates, "*.wxs")
+	if err != nil {
+		return nil, err
+	}
+	if len(tpls) == 0 {
+		return nil, fmt.Errorf("No templates *.wxs found in this directory")
+	}
+
+	if err := os.MkdirAll(out, 0744); err != nil {
+		return nil, err
+	}
+
+	var generated []string
+	for _, tpl := range tpls {
+		dst := filepath.Join(out, filepath.Base(tpl))
+		if _, err := templates.GenerateTemplate(wixFile, tpl, dst); err != nil {
+			return nil, err
+		}
+		generated = append(generated, dst)
+	}
+	opts.Logger.Printf("Generated %d templates", len(generated))
+	return generated, nil
+}
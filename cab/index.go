@@ -0,0 +1,171 @@
+// Package cab writes Microsoft cabinet files compressed with MSZIP,
+// the format Windows Installer expects for embedded media.
+package cab
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	blockSize       = 32768
+	typeMSZIP       = 1
+	headerSize      = 36
+	folderEntrySize = 8
+	attribArchive   = 0x20
+)
+
+// File is a file to store into the cabinet.
+type File struct {
+	Name    string
+	Data    []byte
+	ModTime time.Time
+}
+
+// Writer stores files into a single MSZIP folder.
+type Writer struct {
+	// Level is the deflate compression level, flate.DefaultCompression if nil.
+	Level *int
+	Files []File
+}
+
+// Add appends a file to the cabinet.
+func (w *Writer) Add(name string, data []byte, modTime time.Time) {
+	w.Files = append(w.Files, File{Name: name, Data: data, ModTime: modTime})
+}
+
+// Compression maps a wix compression level name to a deflate level.
+func Compression(name string) (int, error) {
+	switch name {
+	case "", "mszip", "medium":
+		return flate.DefaultCompression, nil
+	case "high":
+		return flate.BestCompression, nil
+	case "low":
+		return flate.BestSpeed, nil
+	case "none":
+		return flate.NoCompression, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q", name)
+}
+
+// WriteTo serializes the cabinet to out.
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	level := flate.DefaultCompression
+	if w.Level != nil {
+		level = *w.Level
+	}
+
+	var uncompressed bytes.Buffer
+	entries := &bytes.Buffer{}
+	for _, f := range w.Files {
+		if uint64(uncompressed.Len())+uint64(len(f.Data)) > 0x7FFF8000 {
+			return 0, fmt.Errorf("cabinet too large")
+		}
+		date, tim := dosTime(f.ModTime)
+		binary.Write(entries, binary.LittleEndian, struct {
+			Size, Offset            uint32
+			Folder, Date, Time, Att uint16
+		}{uint32(len(f.Data)), uint32(uncompressed.Len()), 0, date, tim, attribArchive})
+		entries.WriteString(f.Name)
+		entries.WriteByte(0)
+		uncompressed.Write(f.Data)
+	}
+
+	var blocks bytes.Buffer
+	count := 0
+	data := uncompressed.Bytes()
+	for len(data) > 0 {
+		n := len(data)
+		if n > blockSize {
+			n = blockSize
+		}
+		block, err := compressBlock(data[:n], level)
+		if err != nil {
+			return 0, err
+		}
+		hdr := make([]byte, 8)
+		binary.LittleEndian.PutUint16(hdr[4:], uint16(len(block)))
+		binary.LittleEndian.PutUint16(hdr[6:], uint16(n))
+		binary.LittleEndian.PutUint32(hdr[0:], checksum(hdr[4:8], checksum(block, 0)))
+		blocks.Write(hdr)
+		blocks.Write(block)
+		data = data[n:]
+		count++
+	}
+	if count > 0xFFFF {
+		return 0, fmt.Errorf("cabinet too large")
+	}
+
+	filesOffset := headerSize + folderEntrySize
+	dataOffset := filesOffset + entries.Len()
+	total := dataOffset + blocks.Len()
+
+	var head bytes.Buffer
+	le := binary.LittleEndian
+	head.WriteString("MSCF")
+	binary.Write(&head, le, []uint32{0, uint32(total), 0, uint32(filesOffset), 0})
+	head.Write([]byte{3, 1})
+	binary.Write(&head, le, []uint16{1, uint16(len(w.Files)), 0, 0, 0})
+	binary.Write(&head, le, uint32(dataOffset))
+	binary.Write(&head, le, []uint16{uint16(count), typeMSZIP})
+
+	var n int64
+	for _, b := range [][]byte{head.Bytes(), entries.Bytes(), blocks.Bytes()} {
+		m, err := out.Write(b)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func compressBlock(data []byte, level int) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("CK")
+	fw, err := flate.NewWriter(&b, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// checksum is the cabinet data block checksum algorithm.
+func checksum(data []byte, seed uint32) uint32 {
+	csum := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		csum ^= binary.LittleEndian.Uint32(data[i*4:])
+	}
+	var ul uint32
+	rest := data[n*4:]
+	switch len(rest) {
+	case 3:
+		ul = uint32(rest[0])<<16 | uint32(rest[1])<<8 | uint32(rest[2])
+	case 2:
+		ul = uint32(rest[0])<<8 | uint32(rest[1])
+	case 1:
+		ul = uint32(rest[0])
+	}
+	return csum ^ ul
+}
+
+func dosTime(t time.Time) (uint16, uint16) {
+	if t.IsZero() || t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date := uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+	tim := uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2)
+	return date, tim
+}
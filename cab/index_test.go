@@ -0,0 +1,102 @@
+package cab
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func write(t *testing.T, w *Writer) []byte {
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// blockTypes returns the deflate type of the first block of each MSZIP data block.
+func blockTypes(t *testing.T, data []byte) []byte {
+	le := binary.LittleEndian
+	offset, count := int(le.Uint32(data[headerSize:])), int(le.Uint16(data[headerSize+4:]))
+	var types []byte
+	for i := 0; i < count; i++ {
+		size := int(le.Uint16(data[offset+4:]))
+		block := data[offset+8 : offset+8+size]
+		if string(block[:2]) != "CK" {
+			t.Fatalf("block %d: missing MSZIP signature", i)
+		}
+		types = append(types, block[2]>>1&3)
+		offset += 8 + size
+	}
+	return types
+}
+
+func TestRoundTrip(t *testing.T) {
+	modTime := time.Date(2020, 5, 6, 7, 8, 10, 0, time.Local)
+	big := bytes.Repeat([]byte("go-msi writes cabinets. "), 10000)
+	w := &Writer{}
+	w.Add("hello.exe", big, modTime)
+	w.Add("empty.txt", nil, modTime)
+	w.Add("readme.txt", []byte("hello"), time.Time{})
+
+	files, err := Read(write(t, w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(w.Files) {
+		t.Fatalf("read %d files, want %d", len(files), len(w.Files))
+	}
+	for i, f := range files {
+		if f.Name != w.Files[i].Name || !bytes.Equal(f.Data, w.Files[i].Data) {
+			t.Errorf("file %d = %s of %d bytes, want %s of %d bytes", i, f.Name, len(f.Data), w.Files[i].Name, len(w.Files[i].Data))
+		}
+	}
+	if !files[0].ModTime.Equal(modTime) {
+		t.Errorf("modification time = %s, want %s", files[0].ModTime, modTime)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	data := bytes.Repeat([]byte{'a'}, 200*1024)
+	sizes := map[string]int{}
+	for _, name := range []string{"", "none", "low", "medium", "high", "mszip"} {
+		level, err := Compression(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := &Writer{Level: &level}
+		w.Add("a.txt", data, time.Time{})
+		cab := write(t, w)
+		sizes[name] = len(cab)
+
+		files, err := Read(cab)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(files) != 1 || !bytes.Equal(files[0].Data, data) {
+			t.Errorf("%s: the data is not read back", name)
+		}
+		for i, typ := range blockTypes(t, cab) {
+			if stored := typ == 0; stored != (name == "none") {
+				t.Errorf("%s: block %d has the deflate type %d", name, i, typ)
+			}
+		}
+	}
+	if sizes["none"] <= len(data) || sizes[""] >= len(data)/10 {
+		t.Errorf("cabinet sizes = %v for %d bytes of data", sizes, len(data))
+	}
+
+	w := &Writer{}
+	w.Add("a.txt", data, time.Time{})
+	if got := len(write(t, w)); got != sizes[""] {
+		t.Errorf("the default level writes %d bytes, want the %d bytes of flate.DefaultCompression", got, sizes[""])
+	}
+	if _, err := Compression("ultra"); err == nil {
+		t.Error("unknown compression accepted")
+	}
+	if level, _ := Compression("none"); level != flate.NoCompression {
+		t.Errorf("none is the level %d", level)
+	}
+}
@@ -0,0 +1,110 @@
+package cab
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+const typeNone = 0
+
+// Read extracts the files of a single cabinet, spanned cabinets and
+// reserved areas are not supported.
+func Read(data []byte) ([]File, error) {
+	if len(data) < headerSize || string(data[:4]) != "MSCF" {
+		return nil, fmt.Errorf("not a cabinet file")
+	}
+	le := binary.LittleEndian
+	if flags := le.Uint16(data[30:]); flags != 0 {
+		return nil, fmt.Errorf("unsupported cabinet flags %#x", flags)
+	}
+	folderCount := int(le.Uint16(data[26:]))
+	fileCount := int(le.Uint16(data[28:]))
+	if headerSize+folderCount*folderEntrySize > len(data) {
+		return nil, fmt.Errorf("truncated cabinet folders")
+	}
+
+	folders := make([][]byte, folderCount)
+	for i := range folders {
+		entry := data[headerSize+i*folderEntrySize:]
+		var err error
+		if folders[i], err = readFolder(data, int(le.Uint32(entry)), int(le.Uint16(entry[4:])), le.Uint16(entry[6:])); err != nil {
+			return nil, fmt.Errorf("folder %d: %v", i, err)
+		}
+	}
+
+	var files []File
+	offset := int(le.Uint32(data[16:]))
+	for i := 0; i < fileCount; i++ {
+		if offset+16 > len(data) {
+			return nil, fmt.Errorf("truncated cabinet files")
+		}
+		entry := data[offset:]
+		size, start, folder := int(le.Uint32(entry)), int(le.Uint32(entry[4:])), int(le.Uint16(entry[8:]))
+		name := entry[16:]
+		end := bytes.IndexByte(name, 0)
+		if end < 0 {
+			return nil, fmt.Errorf("truncated cabinet file name")
+		}
+		if folder >= len(folders) || start+size > len(folders[folder]) {
+			return nil, fmt.Errorf("file %s out of its folder", name[:end])
+		}
+		files = append(files, File{
+			Name:    string(name[:end]),
+			Data:    folders[folder][start : start+size],
+			ModTime: dosDate(le.Uint16(entry[10:]), le.Uint16(entry[12:])),
+		})
+		offset += 16 + end + 1
+	}
+	return files, nil
+}
+
+// readFolder returns the uncompressed data of the blocks of a folder,
+// the MSZIP blocks use the previous block as their dictionary.
+func readFolder(data []byte, offset, count int, typ uint16) ([]byte, error) {
+	le := binary.LittleEndian
+	var out []byte
+	var dict []byte
+	for i := 0; i < count; i++ {
+		if offset+8 > len(data) {
+			return nil, fmt.Errorf("truncated block %d", i)
+		}
+		hdr := data[offset : offset+8]
+		size, uncompressed := int(le.Uint16(hdr[4:])), int(le.Uint16(hdr[6:]))
+		if offset+8+size > len(data) {
+			return nil, fmt.Errorf("truncated block %d", i)
+		}
+		block := data[offset+8 : offset+8+size]
+		if sum := le.Uint32(hdr); sum != 0 && sum != checksum(hdr[4:8], checksum(block, 0)) {
+			return nil, fmt.Errorf("invalid checksum of block %d", i)
+		}
+		switch typ {
+		case typeNone:
+			dict = block
+		case typeMSZIP:
+			if len(block) < 2 || string(block[:2]) != "CK" {
+				return nil, fmt.Errorf("invalid MSZIP signature of block %d", i)
+			}
+			var err error
+			if dict, err = ioutil.ReadAll(flate.NewReaderDict(bytes.NewReader(block[2:]), dict)); err != nil {
+				return nil, fmt.Errorf("block %d: %v", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported compression type %d", typ)
+		}
+		if len(dict) != uncompressed {
+			return nil, fmt.Errorf("block %d is %d bytes long, expected %d", i, len(dict), uncompressed)
+		}
+		out = append(out, dict...)
+		offset += 8 + size
+	}
+	return out, nil
+}
+
+func dosDate(date, tim uint16) time.Time {
+	return time.Date(int(date>>9)+1980, time.Month(date>>5&0xF), int(date&0x1F),
+		int(tim>>11), int(tim>>5&0x3F), int(tim&0x1F)*2, 0, time.Local)
+}
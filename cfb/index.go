@@ -0,0 +1,385 @@
+// Package cfb implements the compound file binary format (also known as
+// OLE structured storage) used as the container of MSI databases.
+//
+// Only a flat layout is supported: every stream lives directly under the
+// root storage, which is what Windows Installer databases use.
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+const (
+	sectorSize      = 512
+	miniSectorSize  = 64
+	miniStreamLimit = 4096
+	dirEntrySize    = 128
+	headerDIFATSize = 109
+
+	maxRegSect = 0xFFFFFFFA
+	difSect    = 0xFFFFFFFC
+	fatSect    = 0xFFFFFFFD
+	endOfChain = 0xFFFFFFFE
+	freeSect   = 0xFFFFFFFF
+	noStream   = 0xFFFFFFFF
+
+	typeStream  = 2
+	typeRoot    = 5
+	colorRed    = 0
+	colorBlack  = 1
+	maxNameSize = 31
+)
+
+var signature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// CLSID is a class identifier in its on-disk (mixed endian) byte layout.
+type CLSID [16]byte
+
+// ParseCLSID parses a textual {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX} class identifier.
+func ParseCLSID(s string) (CLSID, error) {
+	var id CLSID
+	var d1 uint32
+	var d2, d3 uint16
+	var d4 [8]byte
+	_, err := fmt.Sscanf(strings.Trim(s, "{}"), "%08x-%04x-%04x-%02x%02x-%02x%02x%02x%02x%02x%02x",
+		&d1, &d2, &d3, &d4[0], &d4[1], &d4[2], &d4[3], &d4[4], &d4[5], &d4[6], &d4[7])
+	if err != nil {
+		return id, fmt.Errorf("invalid CLSID %q: %v", s, err)
+	}
+	binary.LittleEndian.PutUint32(id[0:], d1)
+	binary.LittleEndian.PutUint16(id[4:], d2)
+	binary.LittleEndian.PutUint16(id[6:], d3)
+	copy(id[8:], d4[:])
+	return id, nil
+}
+
+// String formats the class identifier as {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX}.
+func (id CLSID) String() string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		binary.LittleEndian.Uint32(id[0:]), binary.LittleEndian.Uint16(id[4:]), binary.LittleEndian.Uint16(id[6:]),
+		id[8], id[9], id[10], id[11], id[12], id[13], id[14], id[15])
+}
+
+type stream struct {
+	name  string
+	utf16 []uint16
+	data  []byte
+	start uint32
+}
+
+// Writer accumulates streams and serializes them as a compound file.
+type Writer struct {
+	CLSID   CLSID
+	streams map[string]*stream
+}
+
+// NewWriter returns an empty compound file writer.
+func NewWriter() *Writer {
+	return &Writer{streams: make(map[string]*stream)}
+}
+
+// Add adds a stream under the root storage, replacing any stream with the same name.
+func (w *Writer) Add(name string, data []byte) error {
+	u := utf16.Encode([]rune(name))
+	if len(u) == 0 || len(u) > maxNameSize {
+		return fmt.Errorf("invalid stream name %q, must be 1 to %d UTF-16 characters", name, maxNameSize)
+	}
+	w.streams[name] = &stream{name: name, utf16: u, data: data}
+	return nil
+}
+
+// compareNames orders directory entries the way the compound file format
+// requires: shorter names first, then a case insensitive comparison.
+func compareNames(a, b []uint16) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	for i := range a {
+		ca, cb := upper(a[i]), upper(b[i])
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	return 0
+}
+
+func upper(c uint16) uint16 {
+	if u := unicode.ToUpper(rune(c)); u < 0x10000 && !utf16.IsSurrogate(u) {
+		return uint16(u)
+	}
+	return c
+}
+
+type allocator struct {
+	fat []uint32
+}
+
+func (a *allocator) chain(count int) uint32 {
+	if count == 0 {
+		return endOfChain
+	}
+	start := uint32(len(a.fat))
+	for i := 1; i < count; i++ {
+		a.fat = append(a.fat, uint32(len(a.fat)+1))
+	}
+	a.fat = append(a.fat, endOfChain)
+	return start
+}
+
+func (a *allocator) mark(count int, value uint32) uint32 {
+	start := uint32(len(a.fat))
+	for i := 0; i < count; i++ {
+		a.fat = append(a.fat, value)
+	}
+	return start
+}
+
+func sectors(size, unit int) int {
+	return (size + unit - 1) / unit
+}
+
+// WriteTo serializes the compound file to out.
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	list := make([]*stream, 0, len(w.streams))
+	for _, s := range w.streams {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return compareNames(list[i].utf16, list[j].utf16) < 0
+	})
+
+	fat := &allocator{}
+	var mini bytes.Buffer
+	var miniFat []uint32
+	for _, s := range list {
+		if len(s.data) >= miniStreamLimit {
+			s.start = fat.chain(sectors(len(s.data), sectorSize))
+			continue
+		}
+		n := sectors(len(s.data), miniSectorSize)
+		if n == 0 {
+			s.start = endOfChain
+			continue
+		}
+		s.start = uint32(len(miniFat))
+		for i := 1; i < n; i++ {
+			miniFat = append(miniFat, uint32(len(miniFat)+1))
+		}
+		miniFat = append(miniFat, endOfChain)
+		mini.Write(s.data)
+		mini.Write(make([]byte, n*miniSectorSize-len(s.data)))
+	}
+	miniStart := fat.chain(sectors(mini.Len(), sectorSize))
+	miniFatStart := fat.chain(sectors(len(miniFat)*4, sectorSize))
+	dirSectors := sectors((len(list)+1)*dirEntrySize, sectorSize)
+	dirStart := fat.chain(dirSectors)
+
+	// The FAT must describe its own sectors and the DIFAT sectors, grow
+	// both until they cover the whole file.
+	fatCount, difatCount := 0, 0
+	for {
+		total := len(fat.fat) + fatCount + difatCount
+		needFat := sectors(total, sectorSize/4)
+		needDifat := 0
+		if needFat > headerDIFATSize {
+			needDifat = sectors(needFat-headerDIFATSize, sectorSize/4-1)
+		}
+		if needFat == fatCount && needDifat == difatCount {
+			break
+		}
+		fatCount, difatCount = needFat, needDifat
+	}
+	fatStart := fat.mark(fatCount, fatSect)
+	difatStart := fat.mark(difatCount, difSect)
+	if len(fat.fat) > maxRegSect {
+		return 0, fmt.Errorf("compound file too large")
+	}
+
+	cw := &countWriter{w: out}
+	if err := w.writeHeader(cw, fatCount, fatStart, difatCount, difatStart, dirStart, miniFatStart, len(miniFat)); err != nil {
+		return cw.n, err
+	}
+	for _, s := range list {
+		if len(s.data) >= miniStreamLimit {
+			if err := writePadded(cw, s.data, sectorSize); err != nil {
+				return cw.n, err
+			}
+		}
+	}
+	if err := writePadded(cw, mini.Bytes(), sectorSize); err != nil {
+		return cw.n, err
+	}
+	if err := writePadded(cw, uint32s(miniFat), sectorSize); err != nil {
+		return cw.n, err
+	}
+	if err := writePadded(cw, w.directory(list, miniStart, mini.Len(), dirSectors), sectorSize); err != nil {
+		return cw.n, err
+	}
+	table := make([]uint32, fatCount*sectorSize/4)
+	for i := range table {
+		table[i] = freeSect
+	}
+	copy(table, fat.fat)
+	if err := writePadded(cw, uint32s(table), sectorSize); err != nil {
+		return cw.n, err
+	}
+	if difatCount > 0 {
+		difat := make([]uint32, difatCount*sectorSize/4)
+		for i := range difat {
+			difat[i] = freeSect
+		}
+		k := 0
+		for i := headerDIFATSize; i < fatCount; i++ {
+			if k%(sectorSize/4) == sectorSize/4-1 {
+				difat[k] = difatStart + uint32(k/(sectorSize/4)) + 1
+				k++
+			}
+			difat[k] = fatStart + uint32(i)
+			k++
+		}
+		difat[len(difat)-1] = endOfChain
+		if err := writePadded(cw, uint32s(difat), sectorSize); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+func (w *Writer) writeHeader(out io.Writer, fatCount int, fatStart uint32, difatCount int, difatStart, dirStart, miniFatStart uint32, miniFatEntries int) error {
+	h := make([]byte, sectorSize)
+	copy(h, signature)
+	le := binary.LittleEndian
+	le.PutUint16(h[24:], 0x003E)
+	le.PutUint16(h[26:], 0x0003)
+	le.PutUint16(h[28:], 0xFFFE)
+	le.PutUint16(h[30:], 9)
+	le.PutUint16(h[32:], 6)
+	le.PutUint32(h[44:], uint32(fatCount))
+	le.PutUint32(h[48:], dirStart)
+	le.PutUint32(h[56:], miniStreamLimit)
+	if miniFatEntries == 0 {
+		le.PutUint32(h[60:], endOfChain)
+	} else {
+		le.PutUint32(h[60:], miniFatStart)
+	}
+	le.PutUint32(h[64:], uint32(sectors(miniFatEntries*4, sectorSize)))
+	if difatCount == 0 {
+		le.PutUint32(h[68:], endOfChain)
+	} else {
+		le.PutUint32(h[68:], difatStart)
+	}
+	le.PutUint32(h[72:], uint32(difatCount))
+	for i := 0; i < headerDIFATSize; i++ {
+		v := uint32(freeSect)
+		if i < fatCount {
+			v = fatStart + uint32(i)
+		}
+		le.PutUint32(h[76+i*4:], v)
+	}
+	_, err := out.Write(h)
+	return err
+}
+
+// directory lays out the directory entries, the streams are arranged
+// as a balanced binary tree below the root entry and colored so that
+// it is a valid red-black tree.
+func (w *Writer) directory(list []*stream, miniStart uint32, miniSize, dirSectors int) []byte {
+	buf := make([]byte, dirSectors*sectorSize)
+	for i := 0; i < dirSectors*sectorSize/dirEntrySize; i++ {
+		e := buf[i*dirEntrySize:]
+		binary.LittleEndian.PutUint32(e[68:], noStream)
+		binary.LittleEndian.PutUint32(e[72:], noStream)
+		binary.LittleEndian.PutUint32(e[76:], noStream)
+	}
+
+	type node struct {
+		left, right uint32
+		depth       int
+	}
+	nodes := make([]node, len(list))
+	maxDepth := 0
+	var build func(lo, hi, depth int) uint32
+	build = func(lo, hi, depth int) uint32 {
+		if lo >= hi {
+			return noStream
+		}
+		mid := (lo + hi) / 2
+		nodes[mid].depth = depth
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		nodes[mid].left = build(lo, mid, depth+1)
+		nodes[mid].right = build(mid+1, hi, depth+1)
+		return uint32(mid + 1)
+	}
+	rootChild := build(0, len(list), 0)
+
+	start := miniStart
+	if miniSize == 0 {
+		start = endOfChain
+	}
+	writeEntry(buf[0:], utf16.Encode([]rune("Root Entry")), typeRoot, colorBlack,
+		noStream, noStream, rootChild, w.CLSID, start, uint64(miniSize))
+	for i, s := range list {
+		color := byte(colorBlack)
+		if maxDepth > 0 && nodes[i].depth == maxDepth {
+			color = colorRed
+		}
+		writeEntry(buf[(i+1)*dirEntrySize:], s.utf16, typeStream, color,
+			nodes[i].left, nodes[i].right, noStream, CLSID{}, s.start, uint64(len(s.data)))
+	}
+	return buf
+}
+
+func writeEntry(e []byte, name []uint16, typ, color byte, left, right, child uint32, clsid CLSID, start uint32, size uint64) {
+	le := binary.LittleEndian
+	for i, c := range name {
+		le.PutUint16(e[i*2:], c)
+	}
+	le.PutUint16(e[64:], uint16((len(name)+1)*2))
+	e[66] = typ
+	e[67] = color
+	le.PutUint32(e[68:], left)
+	le.PutUint32(e[72:], right)
+	le.PutUint32(e[76:], child)
+	copy(e[80:96], clsid[:])
+	le.PutUint32(e[116:], start)
+	le.PutUint64(e[120:], size)
+}
+
+func uint32s(values []uint32) []byte {
+	b := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+func writePadded(w io.Writer, data []byte, unit int) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if pad := len(data) % unit; pad != 0 {
+		_, err := w.Write(make([]byte, unit-pad))
+		return err
+	}
+	return nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
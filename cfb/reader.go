@@ -0,0 +1,223 @@
+package cfb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+)
+
+// Reader gives access to the streams stored under the root storage of a
+// compound file, nested storages are ignored.
+type Reader struct {
+	CLSID   CLSID
+	streams map[string][]byte
+}
+
+type entry struct {
+	name               string
+	typ                byte
+	left, right, child uint32
+	clsid              CLSID
+	start              uint32
+	size               uint64
+}
+
+type reader struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+}
+
+// NewReader parses the compound file held by data.
+func NewReader(data []byte) (*Reader, error) {
+	if len(data) < sectorSize || !bytes.Equal(data[:8], signature) {
+		return nil, fmt.Errorf("not a compound file")
+	}
+	le := binary.LittleEndian
+	if le.Uint16(data[28:]) != 0xFFFE {
+		return nil, fmt.Errorf("invalid compound file byte order")
+	}
+	shift := le.Uint16(data[30:])
+	if shift != 9 && shift != 12 {
+		return nil, fmt.Errorf("invalid compound file sector shift %d", shift)
+	}
+	r := &reader{data: data, sectorSize: 1 << shift}
+	fatCount := int(le.Uint32(data[44:]))
+	dirStart := le.Uint32(data[48:])
+	cutoff := uint64(le.Uint32(data[56:]))
+	miniFatStart := le.Uint32(data[60:])
+	difatNext := le.Uint32(data[68:])
+
+	var difat []uint32
+	for i := 0; i < headerDIFATSize; i++ {
+		difat = append(difat, le.Uint32(data[76+i*4:]))
+	}
+	perSector := r.sectorSize / 4
+	for seen := 0; difatNext < maxRegSect && len(difat) < fatCount; seen++ {
+		s, err := r.sector(difatNext)
+		if err != nil || seen > len(data)/r.sectorSize {
+			return nil, fmt.Errorf("invalid compound file DIFAT")
+		}
+		for i := 0; i < perSector-1; i++ {
+			difat = append(difat, le.Uint32(s[i*4:]))
+		}
+		difatNext = le.Uint32(s[(perSector-1)*4:])
+	}
+	if fatCount > len(difat) {
+		return nil, fmt.Errorf("invalid compound file FAT count %d", fatCount)
+	}
+	for _, sect := range difat[:fatCount] {
+		s, err := r.sector(sect)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < perSector; i++ {
+			r.fat = append(r.fat, le.Uint32(s[i*4:]))
+		}
+	}
+
+	dir, err := r.chain(dirStart, r.fat, r.sector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compound file directory: %v", err)
+	}
+	var entries []entry
+	for i := 0; i+dirEntrySize <= len(dir); i += dirEntrySize {
+		entries = append(entries, readEntry(dir[i:i+dirEntrySize]))
+	}
+	if len(entries) == 0 || entries[0].typ != typeRoot {
+		return nil, fmt.Errorf("invalid compound file root entry")
+	}
+	root := entries[0]
+
+	var miniFat []uint32
+	if miniFatStart < maxRegSect {
+		b, err := r.chain(miniFatStart, r.fat, r.sector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid compound file mini FAT: %v", err)
+		}
+		for i := 0; i+4 <= len(b); i += 4 {
+			miniFat = append(miniFat, le.Uint32(b[i:]))
+		}
+	}
+	var mini []byte
+	if root.start < maxRegSect {
+		if mini, err = r.chain(root.start, r.fat, r.sector); err != nil {
+			return nil, fmt.Errorf("invalid compound file mini stream: %v", err)
+		}
+	}
+	miniSector := func(id uint32) ([]byte, error) {
+		off := int(id) * miniSectorSize
+		if off+miniSectorSize > len(mini) {
+			return nil, fmt.Errorf("mini sector %d out of range", id)
+		}
+		return mini[off : off+miniSectorSize], nil
+	}
+
+	out := &Reader{CLSID: root.clsid, streams: make(map[string][]byte)}
+	visited := make(map[uint32]bool)
+	var walk func(id uint32) error
+	walk = func(id uint32) error {
+		if id == noStream {
+			return nil
+		}
+		if int(id) >= len(entries) || visited[id] {
+			return fmt.Errorf("invalid compound file directory tree")
+		}
+		visited[id] = true
+		e := entries[id]
+		if err := walk(e.left); err != nil {
+			return err
+		}
+		if e.typ == typeStream && e.size == 0 {
+			out.streams[e.name] = nil
+		} else if e.typ == typeStream {
+			var b []byte
+			var err error
+			if e.size < cutoff {
+				b, err = r.chain(e.start, miniFat, miniSector)
+			} else {
+				b, err = r.chain(e.start, r.fat, r.sector)
+			}
+			if err != nil {
+				return fmt.Errorf("stream %q: %v", e.name, err)
+			}
+			if uint64(len(b)) < e.size {
+				return fmt.Errorf("stream %q is truncated", e.name)
+			}
+			out.streams[e.name] = b[:e.size]
+		}
+		return walk(e.right)
+	}
+	if err := walk(root.child); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func readEntry(b []byte) entry {
+	le := binary.LittleEndian
+	n := int(le.Uint16(b[64:])) / 2
+	if n > 32 {
+		n = 32
+	}
+	name := make([]uint16, 0, n)
+	for i := 0; i < n; i++ {
+		if c := le.Uint16(b[i*2:]); c != 0 {
+			name = append(name, c)
+		}
+	}
+	e := entry{
+		name:  string(utf16.Decode(name)),
+		typ:   b[66],
+		left:  le.Uint32(b[68:]),
+		right: le.Uint32(b[72:]),
+		child: le.Uint32(b[76:]),
+		start: le.Uint32(b[116:]),
+		size:  le.Uint64(b[120:]),
+	}
+	copy(e.clsid[:], b[80:96])
+	return e
+}
+
+func (r *reader) sector(id uint32) ([]byte, error) {
+	off := (int(id) + 1) * r.sectorSize
+	if id >= maxRegSect || off+r.sectorSize > len(r.data) {
+		return nil, fmt.Errorf("sector %d out of range", id)
+	}
+	return r.data[off : off+r.sectorSize], nil
+}
+
+// chain concatenates the sectors of the chain starting at start.
+func (r *reader) chain(start uint32, table []uint32, sector func(uint32) ([]byte, error)) ([]byte, error) {
+	var out []byte
+	for id, n := start, 0; id != endOfChain; n++ {
+		if int(id) >= len(table) || n > len(table) {
+			return nil, fmt.Errorf("broken sector chain")
+		}
+		s, err := sector(id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s...)
+		id = table[id]
+	}
+	return out, nil
+}
+
+// Streams returns the names of the streams, sorted.
+func (r *Reader) Streams() []string {
+	names := make([]string, 0, len(r.streams))
+	for name := range r.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stream returns the content of the named stream.
+func (r *Reader) Stream(name string) ([]byte, bool) {
+	b, ok := r.streams[name]
+	return b, ok
+}
@@ -0,0 +1,116 @@
+package manifest
+
+import "strings"
+
+// DataRoots maps the roots of the data directories to their msi directory.
+var DataRoots = map[string]string{
+	"program-data":   "CommonAppDataFolder",
+	"app-data":       "AppDataFolder",
+	"local-app-data": "LocalAppDataFolder",
+	"install":        "INSTALLDIR",
+}
+
+// DataFolder is a directory of the tree of the data directories,
+// the roots are the msi directories of DataRoots.
+type DataFolder struct {
+	ID        string
+	Name      string
+	Folders   []DataFolder
+	Directory *DataDirectory // created at this folder, if any
+}
+
+// DataPath splits the path of a data directory into its names.
+func DataPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' })
+}
+
+// dataFolders builds the tree of the data directories. The directories
+// in the profile of the user are removed on uninstall when empty, by the
+// component of the first data directory in them, and the key path of
+// their components is a registry value in HKCU.
+func (wixFile *WixManifest) dataFolders() {
+	wixFile.DataFolders = nil
+	ids := identifiers{}
+	folders := map[string]string{} // ids of the folders by path
+	removed := map[string]bool{}   // folders removed by a component
+	for i := range wixFile.DataDirectories {
+		d := &wixFile.DataDirectories[i]
+		if d.Root == "" {
+			d.Root = "program-data"
+		}
+		if d.Uninstall == "" {
+			d.Uninstall = "keep"
+		}
+		for j := range d.Permissions {
+			p := &d.Permissions[j]
+			p.Domain, p.Account = "", p.User
+			if k := strings.LastIndex(p.User, `\`); k >= 0 {
+				p.Domain, p.Account = p.User[:k], p.User[k+1:]
+			}
+		}
+		perUser := d.Root == "app-data" || d.Root == "local-app-data"
+		d.KeyRoot = wixFile.RegistryRoot()
+		if perUser {
+			d.KeyRoot = "HKCU"
+		}
+
+		key := d.Root
+		parent := DataRoots[d.Root]
+		d.ID, d.Folder, d.RemoveFolders = "", parent, nil
+		for _, name := range DataPath(d.Path) {
+			key += "/" + strings.ToLower(name)
+			id, ok := folders[key]
+			if !ok {
+				id = "DataFolder_" + ids.make(key)
+				folders[key] = id
+				wixFile.addDataFolder(parent, DataFolder{ID: id, Name: name})
+			}
+			parent = id
+			d.Folder = id
+			if perUser && !removed[id] {
+				removed[id] = true
+				d.RemoveFolders = append(d.RemoveFolders, id)
+			}
+		}
+		d.ID = strings.TrimPrefix(d.Folder, "DataFolder_")
+		d.PathProperty = "DATAPATH_" + strings.ToUpper(d.ID)
+		d.PurgeProperty = "DATAPURGE_" + strings.ToUpper(d.ID)
+	}
+	// link the data directories once the tree no longer grows
+	var link func(fs []DataFolder)
+	link = func(fs []DataFolder) {
+		for i := range fs {
+			for j := range wixFile.DataDirectories {
+				if wixFile.DataDirectories[j].Folder == fs[i].ID {
+					fs[i].Directory = &wixFile.DataDirectories[j]
+				}
+			}
+			link(fs[i].Folders)
+		}
+	}
+	for i := range wixFile.DataFolders {
+		link(wixFile.DataFolders[i].Folders)
+	}
+}
+
+// addDataFolder adds f to the folder parent of the tree.
+func (wixFile *WixManifest) addDataFolder(parent string, f DataFolder) {
+	var find func(fs []DataFolder) *DataFolder
+	find = func(fs []DataFolder) *DataFolder {
+		for i := range fs {
+			if fs[i].ID == parent {
+				return &fs[i]
+			}
+			if found := find(fs[i].Folders); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	p := find(wixFile.DataFolders)
+	if p == nil {
+		wixFile.DataFolders = append(wixFile.DataFolders, DataFolder{ID: parent})
+		p = &wixFile.DataFolders[len(wixFile.DataFolders)-1]
+	}
+	p.Folders = append(p.Folders, f)
+}
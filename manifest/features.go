@@ -0,0 +1,70 @@
+package manifest
+
+// DefaultFeature is the id of the root feature, it holds the components
+// without a feature and is installed along any other feature.
+const DefaultFeature = "DefaultFeature"
+
+// assignFeatures lists the components of each feature, the components
+// are named as in the templates. The components of an unknown feature
+// belong to the default feature.
+func (wixFile *WixManifest) assignFeatures() {
+	features := map[string]*Feature{}
+	var index func(fs []Feature)
+	index = func(fs []Feature) {
+		for i := range fs {
+			f := &fs[i]
+			f.Components = nil
+			if f.Title == "" {
+				f.Title = f.ID
+			}
+			if f.Level == 0 {
+				f.Level = 1
+			}
+			features[f.ID] = f
+			index(f.Features)
+		}
+	}
+	index(wixFile.Features)
+
+	wixFile.DefaultComponents = nil
+	add := func(feature, component string) {
+		if f, ok := features[feature]; ok {
+			f.Components = append(f.Components, component)
+		} else {
+			wixFile.DefaultComponents = append(wixFile.DefaultComponents, component)
+		}
+	}
+	for _, e := range wixFile.Environments {
+		add(e.Feature, "Environments_"+e.ID)
+	}
+	var files func(dir Directory, feature string)
+	files = func(dir Directory, feature string) {
+		if dir.Feature != "" {
+			feature = dir.Feature
+		}
+		for _, f := range dir.Files {
+			if f.Feature != "" {
+				add(f.Feature, "ApplicationFiles_"+f.ID)
+			} else {
+				add(feature, "ApplicationFiles_"+f.ID)
+			}
+		}
+		for _, d := range dir.Directories {
+			files(d, feature)
+		}
+	}
+	files(wixFile.Directory, "")
+	for _, r := range wixFile.Registries {
+		add(r.Feature, "RegistryEntries_"+r.ID)
+	}
+	add("", "RegistryEntriesARP")
+	for _, s := range wixFile.Shortcuts {
+		add(s.Feature, "ApplicationShortcuts_"+s.ID)
+	}
+	for _, d := range wixFile.DataDirectories {
+		add(d.Feature, "DataDirectory_"+d.ID)
+	}
+	for _, c := range wixFile.ServiceControls {
+		add(c.Feature, "ServiceControls_"+c.ID)
+	}
+}
@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Field is a string value of the manifest and its path in the json file,
+// for example shortcuts[1].target.
+type Field struct {
+	Path  string
+	Value string
+}
+
+// Fields lists the non empty string values of the manifest.
+// Values computed from the json file, tagged with source, are reported
+// under the path of the json field they are computed from.
+func (wixFile *WixManifest) Fields() []Field {
+	var fields []Field
+	walkFields(reflect.ValueOf(wixFile).Elem(), "", func(v reflect.Value, path string) {
+		if v.Kind() == reflect.String && v.String() != "" {
+			fields = append(fields, Field{Path: path, Value: v.String()})
+		}
+	})
+	return fields
+}
+
+// Item identifies a struct of the manifest, such as a file or a shortcut,
+// by its address and its type, as the address of a struct is also the
+// address of its first field.
+type Item struct {
+	Addr uintptr
+	Type reflect.Type
+}
+
+// ItemOf returns the item of v, a struct of the manifest or a pointer to it,
+// it returns false when v is not addressable.
+func ItemOf(v reflect.Value) (Item, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return Item{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || !v.CanAddr() {
+		return Item{}, false
+	}
+	return Item{Addr: v.UnsafeAddr(), Type: v.Type()}, true
+}
+
+// Items maps the structs of the manifest to their path in the json file,
+// for example shortcuts[1] or files[0].services[1].
+func (wixFile *WixManifest) Items() map[Item]string {
+	items := make(map[Item]string)
+	walkFields(reflect.ValueOf(wixFile).Elem(), "", func(v reflect.Value, path string) {
+		if item, ok := ItemOf(v); ok && path != "" {
+			items[item] = path
+		}
+	})
+	return items
+}
+
+// walkFields calls visit with the values of the json fields below v and their path.
+func walkFields(v reflect.Value, path string, visit func(v reflect.Value, path string)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			walkFields(v.Elem(), path, visit)
+		}
+	case reflect.String:
+		visit(v, path)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkFields(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	case reflect.Struct:
+		visit(v, path)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if src := f.Tag.Get("source"); src != "" {
+				name = src
+			} else if name == "-" {
+				continue
+			}
+			switch {
+			case f.Anonymous && name == "":
+				walkFields(v.Field(i), path, visit)
+			case path == "":
+				walkFields(v.Field(i), name, visit)
+			default:
+				walkFields(v.Field(i), path+"."+name, visit)
+			}
+		}
+	}
+}
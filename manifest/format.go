@@ -0,0 +1,650 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest formats.
+const (
+	FormatJSON  = "json"
+	FormatJSONC = "jsonc" // json with comments and trailing commas
+	FormatYAML  = "yaml"
+	FormatTOML  = "toml"
+)
+
+// Filenames lists the manifest files searched, in order,
+// when no manifest path is given.
+var Filenames = []string{"wix.json", "wix.jsonc", "wix.yaml", "wix.yml", "wix.toml"}
+
+// FormatOf returns the format of the manifest file p after its extension.
+func FormatOf(p string) (string, error) {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".jsonc":
+		return FormatJSONC, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("unknown manifest format of %q, the extension must be one of .json, .jsonc, .yaml, .yml or .toml", p)
+}
+
+// FindFile returns the first manifest of Filenames found in dir.
+func FindFile(dir string) (string, error) {
+	for _, name := range Filenames {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	_, err := os.Stat(filepath.Join(dir, Filenames[0]))
+	return "", err
+}
+
+// ToJSON converts the data of a manifest in the given format to json.
+func ToJSON(data []byte, format string) ([]byte, error) {
+	if format == FormatJSON {
+		return data, nil
+	}
+	tree, err := decode(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(tree)
+}
+
+// FromJSON converts json data to a manifest in the given format.
+func FromJSON(data []byte, format string) ([]byte, error) {
+	tree, err := decode(data, FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+	return encode(tree, format)
+}
+
+// Convert translates the data of a manifest from a format to another,
+// the keys order and the keys unknown to WixManifest are kept, except
+// that toml writes the values of a table before its sub tables.
+func Convert(data []byte, from, to string) ([]byte, error) {
+	tree, err := decode(data, from)
+	if err != nil {
+		return nil, err
+	}
+	return encode(tree, to)
+}
+
+// ConvertFile translates the manifest file in to the file out,
+// the formats derive from their extensions.
+func ConvertFile(in, out string) error {
+	from, err := FormatOf(in)
+	if err != nil {
+		return err
+	}
+	to, err := FormatOf(out)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	if data, err = Convert(data, from, to); err != nil {
+		return fmt.Errorf("%s: %v", in, err)
+	}
+	return ioutil.WriteFile(out, data, 0644)
+}
+
+// object is a decoded map which remembers the order of its keys.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+// The decoded trees are made of object, []interface{}, string,
+// json.Number, bool and nil values.
+
+func decode(data []byte, format string) (interface{}, error) {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(data)
+	case FormatJSONC:
+		return decodeJSON(StripComments(data))
+	case FormatYAML:
+		return decodeYAML(data)
+	case FormatTOML:
+		return decodeTOML(data)
+	}
+	return nil, fmt.Errorf("unknown manifest format %q", format)
+}
+
+func encode(tree interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON, FormatJSONC:
+		return encodeJSON(tree)
+	case FormatYAML:
+		return yaml.Marshal(toYAML(tree))
+	case FormatTOML:
+		return encodeTOML(tree)
+	}
+	return nil, fmt.Errorf("unknown manifest format %q", format)
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid json, unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		o := object{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{key: k.(string), value: v})
+		}
+		_, err := dec.Token()
+		return o, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err := dec.Token()
+		return a, err
+	}
+	return t, nil
+}
+
+// StripComments turns json with comments and trailing commas into json,
+// the comments are replaced by spaces to keep the offsets of the values.
+func StripComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(data) {
+				j = len(data) - 1
+			}
+			out = append(out, data[i:j+1]...)
+			i = j
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for ; i < len(data) && data[i] != '\n'; i++ {
+				out = append(out, ' ')
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 4
+			}
+			for ; i < end; i++ {
+				if data[i] == '\n' {
+					out = append(out, '\n')
+				} else {
+					out = append(out, ' ')
+				}
+			}
+			i--
+		case c == ',':
+			j := skipComments(data, i+1)
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				out = append(out, ' ')
+			} else {
+				out = append(out, c)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// skipComments returns the offset of the first byte after i
+// which is neither a space nor in a comment.
+func skipComments(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n':
+			i++
+		case bytes.HasPrefix(data[i:], []byte("//")):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case bytes.HasPrefix(data[i:], []byte("/*")):
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return len(data)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func encodeJSON(tree interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, tree); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case object:
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(m.key)
+			buf.Write(k)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, m.value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	return nil
+}
+
+func decodeYAML(data []byte) (interface{}, error) {
+	// decoding into a MapSlice decodes the nested maps into MapSlice too,
+	// which keeps the keys order.
+	var v yaml.MapSlice
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return fromYAML(v)
+}
+
+func fromYAML(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		o := object{}
+		for _, item := range v {
+			value, err := fromYAML(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{key: fmt.Sprint(item.Key), value: value})
+		}
+		return o, nil
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if a[i], err = fromYAML(e); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return fromScalar(v)
+}
+
+// fromScalar converts a decoded yaml or toml scalar.
+func fromScalar(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, string, bool:
+		return v, nil
+	case int:
+		return json.Number(strconv.Itoa(v)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("unsupported number %v", v)
+		}
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("unsupported value %v of type %T", v, v)
+}
+
+func toYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case object:
+		m := yaml.MapSlice{}
+		for _, e := range v {
+			m = append(m, yaml.MapItem{Key: e.key, Value: toYAML(e.value)})
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = toYAML(e)
+		}
+		return a
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return string(v)
+	}
+	return v
+}
+
+func decodeTOML(data []byte) (interface{}, error) {
+	var v map[string]interface{}
+	md, err := toml.Decode(string(data), &v)
+	if err != nil {
+		return nil, err
+	}
+	// the toml decoder returns maps, restore the keys order out of
+	// the keys listed in the order of the document.
+	order := map[string]int{}
+	for i, key := range md.Keys() {
+		k := strings.Join(key, "\x00")
+		if _, ok := order[k]; !ok {
+			order[k] = i
+		}
+	}
+	return fromTOML(v, nil, order)
+}
+
+func fromTOML(v interface{}, path []string, order map[string]int) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		index := func(k string) int {
+			if i, ok := order[strings.Join(append(append([]string{}, path...), k), "\x00")]; ok {
+				return i
+			}
+			return math.MaxInt32
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := index(keys[i]), index(keys[j])
+			if a != b {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+		o := object{}
+		for _, k := range keys {
+			value, err := fromTOML(v[k], append(append([]string{}, path...), k), order)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{key: k, value: value})
+		}
+		return o, nil
+	case []map[string]interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if a[i], err = fromTOML(e, path, order); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			var err error
+			if a[i], err = fromTOML(e, path, order); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return fromScalar(v)
+}
+
+// encodeTOML writes the tree as a toml document, the values of each table
+// come first, followed by its sub tables and its arrays of tables.
+// Toml has no null, the null values are dropped.
+func encodeTOML(tree interface{}) ([]byte, error) {
+	o, ok := tree.(object)
+	if !ok {
+		return nil, fmt.Errorf("a toml document must be a table")
+	}
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, nil, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTOMLTable(buf *bytes.Buffer, path []string, o object) error {
+	for _, m := range o {
+		if m.value == nil || isTOMLTable(m.value) || isTOMLArrayOfTables(m.value) {
+			continue
+		}
+		buf.WriteString(tomlKey(m.key) + " = ")
+		if err := writeTOMLValue(buf, m.value); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+	for _, m := range o {
+		sub := append(append([]string{}, path...), m.key)
+		switch {
+		case isTOMLTable(m.value):
+			fmt.Fprintf(buf, "\n[%s]\n", tomlPath(sub))
+			if err := writeTOMLTable(buf, sub, m.value.(object)); err != nil {
+				return err
+			}
+		case isTOMLArrayOfTables(m.value):
+			for _, e := range m.value.([]interface{}) {
+				fmt.Fprintf(buf, "\n[[%s]]\n", tomlPath(sub))
+				if err := writeTOMLTable(buf, sub, e.(object)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func isTOMLTable(v interface{}) bool {
+	_, ok := v.(object)
+	return ok
+}
+
+func isTOMLArrayOfTables(v interface{}) bool {
+	a, ok := v.([]interface{})
+	if !ok || len(a) == 0 {
+		return false
+	}
+	for _, e := range a {
+		if _, ok := e.(object); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTOMLValue(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case object:
+		buf.WriteString("{")
+		n := 0
+		for _, m := range v {
+			if m.value == nil {
+				continue
+			}
+			if n > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(" " + tomlKey(m.key) + " = ")
+			if err := writeTOMLValue(buf, m.value); err != nil {
+				return err
+			}
+			n++
+		}
+		if n > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString("}")
+	case []interface{}:
+		buf.WriteString("[")
+		for i, e := range v {
+			if e == nil {
+				return fmt.Errorf("toml arrays cannot contain null values")
+			}
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeTOMLValue(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case string:
+		buf.WriteString(tomlString(v))
+	case json.Number:
+		s := string(v)
+		if _, err := v.Int64(); err != nil && !strings.ContainsAny(s, ".eE") {
+			// integers out of the toml range are written as floats
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	default:
+		return fmt.Errorf("unsupported value %v of type %T", v, v)
+	}
+	return nil
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if bareKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlString quotes s, the paths and commands full of backslashes
+// are written as literal strings.
+func tomlString(s string) string {
+	literal := strings.Contains(s, `\`) && !strings.Contains(s, "'")
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			literal = false
+		}
+	}
+	if literal {
+		return "'" + s + "'"
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
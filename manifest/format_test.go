@@ -0,0 +1,130 @@
+package manifest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	hello, err := ioutil.ReadFile(filepath.Join("..", "testing", "hello", "wix.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		data string
+	}{
+		{"hello", string(hello)},
+		{"values", `{
+  "z-last-first": "keeps the key order",
+  "quoted": "a \"b\" c:\\d # e",
+  "unicode": "héllo ✓",
+  "multiline": "a\nb",
+  "numbers": [0, -1, 2.5, 10000000],
+  "bools": [true, false],
+  "empty-array": [],
+  "empty-object": {},
+  "nested": {"a": [{"b": {"c": "d"}}, {"b": {}}], "unknown-key": "kept"},
+  "tables": [{"id": "x", "values": [{"name": "n"}]}],
+  "after-tables": "value"
+}`},
+	} {
+		want, err := Convert([]byte(c.data), FormatJSON, FormatJSON)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		yml, err := Convert([]byte(c.data), FormatJSON, FormatYAML)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		back, err := Convert(yml, FormatYAML, FormatJSON)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if string(back) != string(want) {
+			t.Errorf("%s: json -> yaml -> json =\n%s\nwant\n%s", c.name, back, want)
+		}
+
+		tml, err := Convert(yml, FormatYAML, FormatTOML)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if back, err = Convert(tml, FormatTOML, FormatJSON); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// toml moves the values of a table before its sub tables
+		var got, orig interface{}
+		if err := json.Unmarshal(back, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(c.data), &orig); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, orig) {
+			t.Errorf("%s: json -> yaml -> toml -> json =\n%s\nwant\n%s", c.name, back, want)
+		}
+		direct, err := Convert([]byte(c.data), FormatJSON, FormatTOML)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if string(tml) != string(direct) {
+			t.Errorf("%s: json -> yaml -> toml =\n%s\nwant\n%s", c.name, tml, direct)
+		}
+	}
+
+	// the converted manifests load the same
+	var manifests []WixManifest
+	for _, format := range []string{FormatJSON, FormatYAML, FormatTOML} {
+		data, err := Convert(hello, FormatJSON, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = ToJSON(data, format); err != nil {
+			t.Fatal(err)
+		}
+		var m WixManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		manifests = append(manifests, m)
+	}
+	for i, m := range manifests[1:] {
+		a, _ := json.Marshal(manifests[0])
+		b, _ := json.Marshal(m)
+		if string(a) != string(b) {
+			t.Errorf("manifest %d loads as\n%s\nwant\n%s", i+1, b, a)
+		}
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	for _, c := range []struct {
+		name, in, out string
+	}{
+		{"line comment", "{\"a\": 1 // one\n}", "{\"a\": 1       \n}"},
+		{"block comment", "{/* a\nb */\"a\": 1}", "{    \n    \"a\": 1}"},
+		{"comments in strings", `{"a": "// no", "b": "/* no */"}`, `{"a": "// no", "b": "/* no */"}`},
+		{"escaped quote", `{"a": "\"//", "b": 1}`, `{"a": "\"//", "b": 1}`},
+		{"trailing comma in object", `{"a": 1,}`, `{"a": 1 }`},
+		{"trailing comma in array", "[1, 2,\n]", "[1, 2 \n]"},
+		{"trailing comma before a comment", "[1, // last\n]", "[1         \n]"},
+		{"trailing comma before a block comment", "{\"a\": [1],/* x */}", "{\"a\": [1]        }"},
+		{"comma in string", `{"a": ",}"}`, `{"a": ",}"}`},
+		{"unterminated block comment", "[1] /* x", "[1]     "},
+	} {
+		out := StripComments([]byte(c.in))
+		if string(out) != c.out {
+			t.Errorf("%s: StripComments(%q) = %q, want %q", c.name, c.in, out, c.out)
+		}
+		if len(out) != len(c.in) {
+			t.Errorf("%s: the offsets are not kept", c.name)
+		}
+		var v interface{}
+		if err := json.Unmarshal(out, &v); err != nil {
+			t.Errorf("%s: %q is invalid json: %v", c.name, out, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// Glob calls f with the path relative to dir, slash separated, of the files
+// matching the patterns, each pattern is a comma separated list of globs
+// where * and ** are permitted. With fail, a glob matching nothing is an error.
+func Glob(dir string, patterns []string, f func(match string), fail bool) error {
+	for _, pattern := range patterns {
+		var matches []string
+		for _, file := range strings.Split(pattern, ",") {
+			match, err := doublestar.Glob(filepath.Join(dir, file))
+			if err != nil {
+				return err
+			}
+			if fail && match == nil {
+				return fmt.Errorf("files %q do not exist", pattern)
+			}
+			matches = append(matches, match...)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(filepath.Clean(dir), match)
+			if err != nil {
+				return err
+			}
+			f(filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+// Match tells whether rel, a slash separated path relative to the
+// directory of Glob, matches one of the patterns.
+func Match(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		for _, file := range strings.Split(pattern, ",") {
+			if ok, _ := doublestar.Match(path.Clean(filepath.ToSlash(file)), rel); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AddFile adds file to the directory at rel, a slash separated path
+// relative to dir, creating the missing sub directories.
+// It returns false when the file is already listed.
+func (dir *Directory) AddFile(file File, rel string) bool {
+	names := strings.Split(rel, "/")
+	for _, name := range names[:len(names)-1] {
+		i := 0
+		for i < len(dir.Directories) && dir.Directories[i].Name != name {
+			i++
+		}
+		if i == len(dir.Directories) {
+			dir.Directories = append(dir.Directories, Directory{Name: name})
+		}
+		dir = &dir.Directories[i]
+	}
+	for _, f := range dir.Files {
+		if samePath(f.Path, file.Path) {
+			return false
+		}
+	}
+	dir.Files = append(dir.Files, file)
+	return true
+}
+
+// RemoveFiles removes the files for which remove returns true, and the
+// directories the removal leaves empty. It returns the removed files.
+func (dir *Directory) RemoveFiles(remove func(File) bool) []File {
+	var removed []File
+	files := dir.Files[:0]
+	for _, f := range dir.Files {
+		if remove(f) {
+			removed = append(removed, f)
+		} else {
+			files = append(files, f)
+		}
+	}
+	dir.Files = files
+	dirs := dir.Directories[:0]
+	for _, d := range dir.Directories {
+		r := d.RemoveFiles(remove)
+		removed = append(removed, r...)
+		if len(r) == 0 || len(d.Files) > 0 || len(d.Directories) > 0 || d.SourceDir != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	dir.Directories = dirs
+	return removed
+}
+
+func samePath(a, b string) bool {
+	return path.Clean(filepath.ToSlash(a)) == path.Clean(filepath.ToSlash(b))
+}
+
+// harvest adds the files of the source directories matching
+// their includes and not their excludes, all the files by default.
+func (dir *Directory) harvest() error {
+	if dir.SourceDir != "" {
+		includes := dir.Includes
+		if len(includes) == 0 {
+			includes = []string{"**"}
+		}
+		excluded := map[string]bool{}
+		if err := Glob(dir.SourceDir, dir.Excludes, func(match string) {
+			excluded[match] = true
+		}, false); err != nil {
+			return err
+		}
+		if err := Glob(dir.SourceDir, includes, func(match string) {
+			if !excluded[match] {
+				dir.AddFile(File{Path: filepath.ToSlash(filepath.Join(dir.SourceDir, match))}, match)
+			}
+		}, true); err != nil {
+			return fmt.Errorf("harvesting %s: %v", dir.SourceDir, err)
+		}
+	}
+	for i := range dir.Directories {
+		if err := dir.Directories[i].harvest(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
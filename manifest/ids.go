@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxID is the length of the ids, so that they fit the 72 characters
+// of the msi identifiers along with their longest prefix, such as
+// DataDirectoryRegistry_.
+const maxID = 50
+
+// identifiers derives ids from keys, such as the install path of the files,
+// so that the components keep their id when the manifest changes.
+type identifiers map[string]bool
+
+// make returns the id of key, made of its letters, digits, underscores
+// and periods. The ids of the keys with other characters, too long or
+// already used end with a hash of key, so that a-b.txt and a_b.txt do not
+// depend on their order to get distinct ids.
+func (ids identifiers) make(key string) string {
+	key = strings.ToLower(key)
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, key)
+	if id != key || len(id) > maxID || ids[id] {
+		sum := sha1.Sum([]byte(key))
+		hash := hex.EncodeToString(sum[:8])
+		if n := maxID - len(hash) - 1; len(id) > n {
+			id = id[:n]
+		}
+		id += "_" + hash
+	}
+	// identical keys are numbered in order
+	for n, base := 2, id; ids[id]; n++ {
+		suffix := "_" + strconv.Itoa(n)
+		if len(base)+len(suffix) > maxID {
+			base = base[:maxID-len(suffix)]
+		}
+		id = base + suffix
+	}
+	ids[id] = true
+	return id
+}
+
+// assignIDs sets the ids of the files and directories from their install
+// path, relative to the install directory or to their root, of the
+// environment variables from their name, of the registry entries from their
+// path, of the shortcuts from their location and name, of their properties
+// from the shortcut and their key and of the services
+// and service controls from their name. The directories declaring an id use it as their msi directory.
+func (wixFile *WixManifest) assignIDs() {
+	files, services := identifiers{}, identifiers{}
+	var walk func(dir *Directory, target string)
+	walk = func(dir *Directory, target string) {
+		for i := range dir.Files {
+			f := &dir.Files[i]
+			f.ID = files.make(path.Join(target, f.InstallName()))
+			for _, s := range f.AllServices() {
+				s.ID = services.make(s.Name)
+			}
+		}
+		for i := range dir.Directories {
+			d := &dir.Directories[i]
+			t := path.Join(target, d.Name)
+			if d.Root != "" {
+				t = path.Join(d.Root, d.Name)
+			}
+			d.Folder = d.ID
+			if d.ID == "" {
+				d.Folder = "ApplicationDirectory_" + files.make(t)
+			}
+			walk(d, t)
+		}
+	}
+	walk(&wixFile.Directory, "")
+	for i := range wixFile.ServiceControls {
+		c := &wixFile.ServiceControls[i]
+		c.ID = services.make(c.Name)
+	}
+
+	envs := identifiers{}
+	for i := range wixFile.Environments {
+		e := &wixFile.Environments[i]
+		e.ID = envs.make(e.Name)
+	}
+	registries := identifiers{}
+	for i := range wixFile.Registries {
+		r := &wixFile.Registries[i]
+		r.ID = registries.make(r.Path)
+	}
+	shortcuts := identifiers{}
+	for i := range wixFile.Shortcuts {
+		s := &wixFile.Shortcuts[i]
+		s.ID = shortcuts.make(s.Location + "/" + s.Name)
+		properties := identifiers{}
+		for j := range s.Properties {
+			p := &s.Properties[j]
+			p.ID = properties.make(s.ID + "/" + p.Key)
+		}
+	}
+}
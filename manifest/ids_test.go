@@ -0,0 +1,28 @@
+package manifest
+
+import "testing"
+
+func TestIdentifiers(t *testing.T) {
+	long := "some/very/long/path/to/a/file/installed/deep/in/the/install/directory.txt"
+	for _, c := range []struct {
+		keys []string
+		ids  []string
+	}{
+		{[]string{"hello.exe", "README.md"}, []string{"hello.exe", "readme.md"}},
+		// the lossy ids are hashed whatever their order
+		{[]string{"a_b.txt", "a-b.txt"}, []string{"a_b.txt", "a_b.txt_2f8fc8c6f7b3a385"}},
+		{[]string{"a-b.txt", "a_b.txt"}, []string{"a_b.txt_2f8fc8c6f7b3a385", "a_b.txt"}},
+		{[]string{"assets/file1"}, []string{"assets_file1_95de6f8e2c75edcd"}},
+		{[]string{long}, []string{"some_very_long_path_to_a_file_ins_f2526c253a4fa967"}},
+		// identical keys are numbered
+		{[]string{"hello.exe", "Hello.exe"}, []string{"hello.exe", "hello.exe_cd26caf57c4f9fcd"}},
+		{[]string{"a-b", "a-b"}, []string{"a_b_34fafddda8f42376", "a_b_34fafddda8f42376_2"}},
+	} {
+		ids := identifiers{}
+		for i, key := range c.keys {
+			if id := ids.make(key); id != c.ids[i] {
+				t.Errorf("%q: id of %q = %q, want %q", c.keys, key, id, c.ids[i])
+			}
+		}
+	}
+}
@@ -15,26 +15,43 @@ import (
 	"github.com/google/uuid"
 )
 
-// WixManifest is the struct to decode a wix.json file.
+// WixManifest is the struct to decode a wix.json file,
+// or its yaml, toml and jsonc variants.
 type WixManifest struct {
-	Compression string  `json:"compression,omitempty"`
-	Product     string  `json:"product"`
-	Company     string  `json:"company"`
-	Version     Version `json:"-"`
-	License     string  `json:"license,omitempty"`
-	Banner      string  `json:"banner,omitempty"`
-	Dialog      string  `json:"dialog,omitempty"`
-	Icon        string  `json:"icon,omitempty"`
-	Info        *Info   `json:"info,omitempty"`
-	UpgradeCode string  `json:"upgrade-code"`
+	Compression  string  `json:"compression,omitempty" enum:"high,low,medium,mszip,none" desc:"Compression level of the cabinet"`
+	Product      string  `json:"product" desc:"Name of the product"`
+	Company      string  `json:"company" desc:"Name of the company, the manufacturer of the product"`
+	Version      Version `json:"-"`
+	License      string  `json:"license,omitempty" desc:"Path to the license file, converted to RTF when needed"`
+	Banner       string  `json:"banner,omitempty" desc:"Path to the banner image of the installer dialogs"`
+	Dialog       string  `json:"dialog,omitempty" desc:"Path to the background image of the installer dialogs"`
+	Icon         string  `json:"icon,omitempty" desc:"Path to the icon of the product"`
+	Info         *Info   `json:"info,omitempty" desc:"Program information displayed in the control panel"`
+	UpgradeCode  string  `json:"upgrade-code" desc:"Upgrade code of the product, set by go-msi set-guid"`
+	InstallScope string  `json:"install-scope,omitempty" enum:"per-machine,per-user,dual" default:"per-machine" desc:"Installs the product for all the users, for the current user, or lets the user choose with MSIINSTALLPERUSER"`
+	InstallDir   string  `json:"install-dir,omitempty" desc:"Install directory, a standard folder or a property in brackets followed by the names of the directories, [ProgramFiles]\\<product> by default"`
 	Directory
-	Environments []Environment  `json:"environments,omitempty"`
-	Registries   []RegistryItem `json:"registries,omitempty"`
-	Shortcuts    []Shortcut     `json:"shortcuts,omitempty"`
-	Choco        ChocoSpec      `json:"choco"`
-	Hooks        []Hook         `json:"hooks,omitempty"`
-	Properties   []Property     `json:"properties,omitempty"`
-	Conditions   []Condition    `json:"conditions,omitempty"`
+	Environments    []Environment    `json:"environments,omitempty" desc:"Environment variables to set"`
+	Registries      []RegistryItem   `json:"registries,omitempty" desc:"Registry keys to create"`
+	Shortcuts       []Shortcut       `json:"shortcuts,omitempty" desc:"Shortcuts to create"`
+	Choco           ChocoSpec        `json:"choco" desc:"Chocolatey package metadata"`
+	Hooks           []Hook           `json:"hooks,omitempty" desc:"Commands to run on install or uninstall"`
+	Properties      []Property       `json:"properties,omitempty" desc:"Properties to initialize"`
+	Conditions      []Condition      `json:"conditions,omitempty" desc:"Conditions to check before installation"`
+	Features        []Feature        `json:"features,omitempty" desc:"Features the user can choose to install, below the default feature"`
+	DataDirectories []DataDirectory  `json:"data-directories,omitempty" desc:"Directories created for the data of the product, outside the install directory"`
+	ServiceControls []ServiceControl `json:"service-controls,omitempty" desc:"Existing services to start, stop or remove on install and uninstall"`
+
+	DefaultComponents  []string        `json:"-"` // components of the default feature
+	DataFolders        []DataFolder    `json:"-"` // roots of the tree of the data directories
+	InstallRoot        string          `json:"-"` // root of the install directory
+	InstallFolders     []InstallFolder `json:"-"` // directories of the install directory below its root, INSTALLDIR last
+	RootFolders        []string        `json:"-"` // roots to declare, besides the program files, menu and desktop
+	PasswordProperties []string        `json:"-"` // hidden properties of the service passwords
+
+	source   string      // path of the loaded file
+	original []byte      // content of the loaded file
+	base     interface{} // the loaded manifest, as written by Write
 }
 
 // Version stores version related data in various formats.
@@ -49,29 +66,53 @@ type Version struct {
 // Each member data is named after the matching column name in the uninstall
 // program list.
 type Info struct {
-	Comments         string `json:"comments,omitempty"`
-	Contact          string `json:"contact,omitempty"`
-	HelpLink         string `json:"help-link,omitempty"`
-	SupportTelephone string `json:"support-telephone,omitempty"`
-	SupportLink      string `json:"support-link,omitempty"`
-	UpdateInfoLink   string `json:"update-info-link,omitempty"`
-	Readme           string `json:"readme,omitempty"`
+	Comments         string `json:"comments,omitempty" desc:"Comments"`
+	Contact          string `json:"contact,omitempty" desc:"Contact"`
+	HelpLink         string `json:"help-link,omitempty" desc:"Help link"`
+	SupportTelephone string `json:"support-telephone,omitempty" desc:"Support telephone number"`
+	SupportLink      string `json:"support-link,omitempty" desc:"Support link"`
+	UpdateInfoLink   string `json:"update-info-link,omitempty" desc:"Update information link"`
+	Readme           string `json:"readme,omitempty" desc:"Path or link to the readme"`
 	Size             int64  `json:"-"` // in kilobytes
 }
 
 // File is the struct to decode a file.
 type File struct {
-	ID      int      `json:"-"`
-	Path    string   `json:"path,omitempty"`
-	Service *Service `json:"service,omitempty"`
+	ID             string    `json:"-"`
+	GUID           string    `json:"-"` // of the component, when it cannot be generated
+	Path           string    `json:"path,omitempty" desc:"Path to the file to install"`
+	Name           string    `json:"name,omitempty" desc:"Name of the installed file, the name of path by default"`
+	Service        *Service  `json:"service,omitempty" desc:"Windows service run by the file"`
+	Services       []Service `json:"services,omitempty" desc:"Windows services run by the file, after service"`
+	Feature        string    `json:"feature,omitempty" desc:"Id of the feature of the file, the feature of its directory by default"`
+	ReadOnly       string    `json:"read-only,omitempty" enum:"yes,no" default:"no" desc:"Install the file read-only"`
+	Hidden         string    `json:"hidden,omitempty" enum:"yes,no" default:"no" desc:"Install the file hidden"`
+	Vital          string    `json:"vital,omitempty" enum:"yes,no" default:"yes" desc:"Fail the installation when the file cannot be installed"`
+	Permanent      string    `json:"permanent,omitempty" enum:"yes,no" default:"no" desc:"Keep the file on uninstall"`
+	NeverOverwrite string    `json:"never-overwrite,omitempty" enum:"yes,no" default:"no" desc:"Keep the file when it already exists, such as a configuration file the user edits"`
+	Condition      string    `json:"condition,omitempty" desc:"Condition to install the file"`
+}
+
+// InstallName returns the name of the installed file.
+func (file File) InstallName() string {
+	if file.Name != "" {
+		return file.Name
+	}
+	return filepath.Base(filepath.FromSlash(file.Path))
 }
 
 // Directory stores a list of files and a list of sub-directories.
 type Directory struct {
-	ID          int         `json:"-"`
-	Name        string      `json:"name,omitempty"`
-	Files       []File      `json:"files,omitempty"`
-	Directories []Directory `json:"directories,omitempty"`
+	ID          string      `json:"id,omitempty" desc:"Id of the directory, usable as [id] in the manifest, derived from its install path by default"`
+	Folder      string      `json:"-"` // msi directory, the id or ApplicationDirectory_ and the derived id
+	Name        string      `json:"name,omitempty" desc:"Name of the directory"`
+	Root        string      `json:"root,omitempty" desc:"Standard folder, such as CommonFilesFolder or SystemFolder, or property set by a registry search, where the top level directory is installed instead of the install directory"`
+	Files       []File      `json:"files,omitempty" desc:"Files to install"`
+	Directories []Directory `json:"directories,omitempty" desc:"Directories to install"`
+	Feature     string      `json:"feature,omitempty" desc:"Id of the feature of the files of the directory, the feature of its parent by default"`
+	SourceDir   string      `json:"source,omitempty" desc:"Directory whose files are added to the directory when building the package"`
+	Includes    []string    `json:"includes,omitempty" desc:"Globs of the source files to add, relative to source, all the files by default"`
+	Excludes    []string    `json:"excludes,omitempty" desc:"Globs of the source files not to add, relative to source"`
 }
 
 type fileWalker func(file File) (File, error)
@@ -119,30 +160,85 @@ func walkDirectories(dirs []Directory, f directoryWalker) ([]Directory, error) {
 	return dirs, nil
 }
 
+// AllServices returns the services run by the file.
+func (file File) AllServices() []*Service {
+	var services []*Service
+	if file.Service != nil {
+		services = append(services, file.Service)
+	}
+	for i := range file.Services {
+		services = append(services, &file.Services[i])
+	}
+	return services
+}
+
 // Service is the struct to decode a service.
 type Service struct {
-	Name         string   `json:"name"`
-	Bin          string   `json:"-"`
-	Start        string   `json:"start"`
-	Delayed      bool     `json:"-"`
-	DisplayName  string   `json:"display-name,omitempty"`
-	Description  string   `json:"description,omitempty"`
-	Arguments    string   `json:"arguments,omitempty"`
-	Dependencies []string `json:"dependencies,omitempty"`
+	ID                 string       `json:"-"`
+	Name               string       `json:"name" desc:"Name of the service"`
+	Bin                string       `json:"-"`
+	Start              string       `json:"start" enum:"auto,delayed,demand,disabled,boot,system" desc:"Start type of the service"`
+	Delayed            bool         `json:"-"`
+	DisplayName        string       `json:"display-name,omitempty" desc:"Display name of the service"`
+	Description        string       `json:"description,omitempty" desc:"Description of the service"`
+	Arguments          string       `json:"arguments,omitempty" desc:"Arguments passed to the service"`
+	Dependencies       []string     `json:"dependencies,omitempty" desc:"Names of the services the service depends on"`
+	Account            string       `json:"account,omitempty" desc:"Account running the service, LocalSystem by default, LocalService, NetworkService, NT SERVICE\\<name> of the service, a managed service account ending with $ or a user"`
+	Password           string       `json:"password,omitempty" desc:"Id of the public property holding the password of a user account, declared hidden and secure, such as SERVICEPASSWORD given on the command line"`
+	Interactive        string       `json:"interactive,omitempty" enum:"yes,no" default:"no" desc:"Lets the service interact with the desktop, LocalSystem only"`
+	Vital              string       `json:"vital,omitempty" enum:"yes,no" default:"no" desc:"Fail the installation when the service cannot be installed"`
+	StartName          string       `json:"-"` // account as the service control manager names it
+	Recovery           *Recovery    `json:"recovery,omitempty" desc:"Actions taken when the service fails"`
+	SidType            string       `json:"sid-type,omitempty" enum:"none,restricted,unrestricted" desc:"Type of the security identifier of the service"`
+	PreshutdownTimeout int          `json:"preshutdown-timeout,omitempty" desc:"Milliseconds the system waits for the service to stop before shutting down"`
+	Privileges         []string     `json:"privileges,omitempty" desc:"Privileges the service requires, such as SeChangeNotifyPrivilege, all the privileges of its account by default"`
+	EventSource        *EventSource `json:"event-source,omitempty" desc:"Event log source of the service, registered on install and removed on uninstall"`
+}
+
+// EventSource describes the registration of an event log source.
+type EventSource struct {
+	Name        string `json:"name,omitempty" desc:"Name of the source, the name of the service by default"`
+	Log         string `json:"log,omitempty" default:"Application" desc:"Event log of the source"`
+	MessageFile string `json:"message-file,omitempty" desc:"File of the messages of the events, the file of the service by default"`
+	Key         string `json:"-"` // registry key of the source, in HKLM
+}
+
+// Recovery describes the actions taken when a service fails.
+type Recovery struct {
+	First         string `json:"first,omitempty" enum:"none,restart,reboot,run-command" default:"none" desc:"Action taken on the first failure"`
+	Second        string `json:"second,omitempty" enum:"none,restart,reboot,run-command" default:"none" desc:"Action taken on the second failure"`
+	Subsequent    string `json:"subsequent,omitempty" enum:"none,restart,reboot,run-command" default:"none" desc:"Action taken on the subsequent failures"`
+	ResetPeriod   int    `json:"reset-period,omitempty" desc:"Days without failure after which the failure count is reset"`
+	RestartDelay  int    `json:"restart-delay,omitempty" desc:"Seconds before the service is restarted"`
+	Command       string `json:"command,omitempty" desc:"Command line run by the run-command action"`
+	CookedCommand string `json:"-"`
+}
+
+// ServiceControl starts, stops or removes a service the package does
+// not install, such as a service locking the files of the product.
+type ServiceControl struct {
+	ID        string `json:"-"`
+	Name      string `json:"name" desc:"Name of the service"`
+	Start     string `json:"start,omitempty" enum:"install,uninstall,both" desc:"Starts the service on install, uninstall or both"`
+	Stop      string `json:"stop,omitempty" enum:"install,uninstall,both" desc:"Stops the service on install, uninstall or both"`
+	Remove    string `json:"remove,omitempty" enum:"install,uninstall,both" desc:"Removes the service on install, uninstall or both"`
+	Wait      string `json:"wait,omitempty" enum:"yes,no" default:"yes" desc:"Waits up to 30 seconds for the service to start, stop or be removed"`
+	Condition string `json:"condition,omitempty" desc:"Condition to control the service"`
+	Feature   string `json:"feature,omitempty" desc:"Id of the feature of the service control, the default feature when empty"`
 }
 
 // ChocoSpec is the struct to decode the choco key of a wix.json file.
 type ChocoSpec struct {
-	ID             string `json:"id,omitempty"`
-	Title          string `json:"title,omitempty"`
-	Authors        string `json:"authors,omitempty"`
-	Owners         string `json:"owners,omitempty"`
-	Description    string `json:"description,omitempty"`
-	ProjectURL     string `json:"project-url,omitempty"`
-	Tags           string `json:"tags,omitempty"`
-	LicenseURL     string `json:"license-url,omitempty"`
-	IconURL        string `json:"icon-url,omitempty"`
-	RequireLicense bool   `json:"require-license,omitempty"`
+	ID             string `json:"id,omitempty" desc:"Package id, the product name by default"`
+	Title          string `json:"title,omitempty" desc:"Package title, the product name by default"`
+	Authors        string `json:"authors,omitempty" desc:"Package authors, the company by default"`
+	Owners         string `json:"owners,omitempty" desc:"Package owners, the company by default"`
+	Description    string `json:"description,omitempty" desc:"Package description, the product name by default"`
+	ProjectURL     string `json:"project-url,omitempty" desc:"Project URL"`
+	Tags           string `json:"tags,omitempty" desc:"Space separated package tags"`
+	LicenseURL     string `json:"license-url,omitempty" desc:"License URL"`
+	IconURL        string `json:"icon-url,omitempty" desc:"Icon URL"`
+	RequireLicense bool   `json:"require-license,omitempty" desc:"Require the license acceptance"`
 	MsiFile        string `json:"-"`
 	MsiSum         string `json:"-"`
 	BuildDir       string `json:"-"`
@@ -151,147 +247,254 @@ type ChocoSpec struct {
 
 // Hook describes a command to run on install / uninstall.
 type Hook struct {
-	Command       string `json:"command,omitempty"`
-	CookedCommand string `json:"-"`
-	When          string `json:"when,omitempty"`
-	Return        string `json:"return,omitempty"`
-	Condition     string `json:"condition,omitempty"`
-	Impersonate   string `json:"impersonate,omitempty"`
-	Execute       string `json:"execute,omitempty"`
+	Command       string `json:"command,omitempty" desc:"Command line to run"`
+	CookedCommand string `json:"-" source:"command"`
+	When          string `json:"when,omitempty" enum:"install,uninstall" desc:"Run the command on install or uninstall, or whenever the condition is true when empty"`
+	Return        string `json:"return,omitempty" enum:"asyncNoWait,asyncWait,check,ignore" desc:"How the command return is handled"`
+	Condition     string `json:"condition,omitempty" desc:"Condition to run the command"`
+	Impersonate   string `json:"impersonate,omitempty" enum:"yes,no" desc:"Run the command as the user, yes by default for immediate commands, no otherwise"`
+	Execute       string `json:"execute,omitempty" enum:"commit,deferred,firstSequence,immediate,oncePerProcess,rollback,secondSequence" default:"deferred" desc:"Scheduling of the command"`
 }
 
 // Property describes a property to initialize.
 type Property struct {
-	ID       string    `json:"id"`
-	Registry *Registry `json:"registry,omitempty"`
-	Value    *Value    `json:"value,omitempty"`
+	ID       string    `json:"id" desc:"Id of the property"`
+	Registry *Registry `json:"registry,omitempty" desc:"Registry entry to read the property from"`
+	Value    *Value    `json:"value,omitempty" desc:"Value of the property"`
 }
 
 // Registry describes a registry entry.
 type Registry struct {
-	Path string `json:"path"`
-	Root string `json:"-"`
-	Key  string `json:"-"`
-	Name string `json:"name,omitempty"`
+	Path string `json:"path" desc:"Registry path, starting with the root such as HKCU or HKLM"`
+	Root string `json:"-" source:"path"`
+	Key  string `json:"-" source:"path"`
+	Name string `json:"name,omitempty" desc:"Name of the registry value"`
 }
 
 // Value describes a simple string value
 type Value string
 
+// Feature describes an installable part of the product.
+type Feature struct {
+	ID          string             `json:"id" desc:"Id of the feature, as listed in ADDLOCAL"`
+	Title       string             `json:"title,omitempty" desc:"Title of the feature, its id by default"`
+	Description string             `json:"description,omitempty" desc:"Description of the feature"`
+	Level       int                `json:"level,omitempty" default:"1" desc:"Install level of the feature, installed by default up to INSTALLLEVEL, 1"`
+	Conditions  []FeatureCondition `json:"conditions,omitempty" desc:"Conditions changing the install level of the feature"`
+	Features    []Feature          `json:"features,omitempty" desc:"Sub features"`
+	Components  []string           `json:"-"`
+}
+
+// FeatureCondition sets the install level of a feature when its condition is true.
+type FeatureCondition struct {
+	Condition string `json:"condition" desc:"Condition to check"`
+	Level     int    `json:"level" desc:"Install level of the feature when the condition is true, 0 disables it"`
+}
+
 // Condition describes a condition to check before installation.
 type Condition struct {
-	Condition string `json:"condition"`
-	Message   string `json:"message"`
+	Condition string `json:"condition" desc:"Condition to check"`
+	Message   string `json:"message" desc:"Message displayed when the condition is false"`
 }
 
 // Environment is the struct to decode environment variables of the wix.json file.
 type Environment struct {
-	Name      string `json:"name"`
-	Value     string `json:"value"`
-	Permanent string `json:"permanent"`
-	System    string `json:"system"`
-	Action    string `json:"action"`
-	Part      string `json:"part"`
-	Condition string `json:"condition,omitempty"`
+	ID        string `json:"-"`
+	Name      string `json:"name" desc:"Name of the variable"`
+	Value     string `json:"value" desc:"Value of the variable"`
+	Permanent string `json:"permanent" enum:"yes,no" desc:"Keep the variable on uninstall"`
+	System    string `json:"system" enum:"yes,no" desc:"Set a system variable instead of a user variable"`
+	Action    string `json:"action" enum:"create,set,remove" desc:"Action on the variable"`
+	Part      string `json:"part" enum:"all,first,last" desc:"Part of the variable to set"`
+	Condition string `json:"condition,omitempty" desc:"Condition to set the variable"`
+	Feature   string `json:"feature,omitempty" desc:"Id of the feature of the variable, the default feature when empty"`
 }
 
 // Shortcut is the struct to decode shortcut value of the wix.json file.
 type Shortcut struct {
-	Name        string             `json:"name"`
-	Description string             `json:"description"`
-	Location    string             `json:"location"`
-	Target      string             `json:"target"`
-	WDir        string             `json:"wdir,omitempty"`
-	Arguments   string             `json:"arguments,omitempty"`
-	Icon        string             `json:"icon,omitempty"`
-	Condition   string             `json:"condition,omitempty"`
-	Properties  []ShortcutProperty `json:"properties,omitempty"`
+	ID          string             `json:"-"`
+	Name        string             `json:"name" desc:"Name of the shortcut"`
+	Description string             `json:"description" desc:"Description of the shortcut"`
+	Location    string             `json:"location" enum:"program,desktop" desc:"Location of the shortcut, the program menu or the desktop"`
+	Target      string             `json:"target" desc:"Target of the shortcut"`
+	WDir        string             `json:"wdir,omitempty" desc:"Id of the working directory of the shortcut, such as INSTALLDIR"`
+	Arguments   string             `json:"arguments,omitempty" desc:"Arguments of the shortcut"`
+	Icon        string             `json:"icon,omitempty" desc:"Path to the icon of the shortcut"`
+	Condition   string             `json:"condition,omitempty" desc:"Condition to create the shortcut"`
+	Properties  []ShortcutProperty `json:"properties,omitempty" desc:"Properties of the shortcut"`
+	Feature     string             `json:"feature,omitempty" desc:"Id of the feature of the shortcut, the default feature when empty"`
+}
+
+// DataDirectory describes a directory created on install, with its permissions.
+type DataDirectory struct {
+	ID          string       `json:"-"`
+	Root        string       `json:"root,omitempty" enum:"program-data,app-data,local-app-data,install" default:"program-data" desc:"Directory the path is relative to, ProgramData, the roaming or local AppData of the user, or the install directory"`
+	Path        string       `json:"path" desc:"Path of the directory in root, such as Company\\Product\\logs"`
+	Permissions []Permission `json:"permissions,omitempty" desc:"Permissions granted on the directory"`
+	Uninstall   string       `json:"uninstall,omitempty" enum:"keep,remove,remove-on-purge" default:"keep" desc:"Keep the content of the directory on uninstall, remove it, or remove it only when PURGE is set"`
+	Feature     string       `json:"feature,omitempty" desc:"Id of the feature of the directory, the default feature when empty"`
+
+	Folder        string   `json:"-"` // id of the msi directory
+	RemoveFolders []string `json:"-"` // ids of the msi directories to remove on uninstall
+	KeyRoot       string   `json:"-"` // registry root of the key path of the component
+	PathProperty  string   `json:"-"` // public property of the path, read from the registry
+	PurgeProperty string   `json:"-"` // set to the path when PURGE is set
+}
+
+// Permission grants access to a user, a group or a service account.
+type Permission struct {
+	User   string `json:"user" desc:"User, group or account, such as Users, Administrators, NT AUTHORITY\\LocalService or NT SERVICE\\HelloSvc"`
+	Access string `json:"access,omitempty" enum:"read,modify,full" default:"read" desc:"Access granted, read and execute, modify or full control"`
+
+	Domain  string `json:"-"` // domain part of user
+	Account string `json:"-"` // name part of user
 }
 
 // ShortcutProperty stands for a key value association.
 type ShortcutProperty struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	ID    string `json:"-"`
+	Key   string `json:"key" desc:"Key of the property"`
+	Value string `json:"value" desc:"Value of the property"`
 }
 
 // RegistryItem is the struct to decode a registry item.
 type RegistryItem struct {
+	ID string `json:"-"`
 	Registry
-	Values    []RegistryValue `json:"values,omitempty"`
-	Condition string          `json:"condition,omitempty"`
+	Values    []RegistryValue `json:"values,omitempty" desc:"Values of the registry key"`
+	Condition string          `json:"condition,omitempty" desc:"Condition to create the registry key"`
+	Feature   string          `json:"feature,omitempty" desc:"Id of the feature of the registry key, the default feature when empty"`
 }
 
 // RegistryValue is the struct to decode a registry value.
 type RegistryValue struct {
-	Name  string `json:"name"`
-	Type  string `json:"type,omitempty"` // string (default if omitted), integer, ...
-	Value string `json:"value"`
+	Name  string `json:"name" desc:"Name of the value, the default value when empty"`
+	Type  string `json:"type,omitempty" enum:"string,integer,binary,expandable,multiString" default:"string" desc:"Type of the value"` // string (default if omitted), integer, ...
+	Value string `json:"value" desc:"Data of the value"`
 }
 
-// Write the manifest to the given file,
-// if file is empty, writes to wix.json
+// Write the manifest to the given file in the format of its extension,
+// if file is empty, writes to the loaded file or wix.json.
+// When the manifest was loaded, only the changes made since then
+// are applied to the loaded document.
 func (wixFile *WixManifest) Write(p string) error {
 	if p == "" {
-		p = "wix.json"
+		p = wixFile.Source()
+	}
+	format, err := FormatOf(p)
+	if err != nil {
+		return err
 	}
 	byt, err := json.MarshalIndent(wixFile, "", "  ")
 	if err != nil {
 		return err
 	}
+	ours := byt
+	if wixFile.original != nil {
+		if byt, err = wixFile.patch(byt, format); err != nil {
+			return err
+		}
+	} else if format != FormatJSON {
+		if byt, err = FromJSON(byt, format); err != nil {
+			return err
+		}
+	}
 	err = ioutil.WriteFile(p, byt, 0644)
 	if err != nil {
 		return err
 	}
-	return nil
+	if p == wixFile.Source() {
+		wixFile.original = byt
+		wixFile.base, err = decodeJSON(ours)
+	}
+	return err
 }
 
-// Load the manifest from given file path,
-// if the file path is empty, reads from wix.json
+// Load the manifest from given file path in the format of its extension,
+// if the file path is empty, reads from the first file of Filenames found.
 func (wixFile *WixManifest) Load(p string) error {
 	if p == "" {
-		p = "wix.json"
+		var err error
+		if p, err = FindFile("."); err != nil {
+			return err
+		}
+	}
+	format, err := FormatOf(p)
+	if err != nil {
+		return err
 	}
 	if _, err := os.Stat(p); os.IsNotExist(err) {
 		return err
 	}
 	dat, err := ioutil.ReadFile(p)
 	if err != nil {
-		return fmt.Errorf("JSON ReadFile failed with %v", err)
+		return fmt.Errorf("%s ReadFile failed with %v", strings.ToUpper(format), err)
+	}
+	original := dat
+	if format != FormatJSON {
+		if dat, err = ToJSON(dat, format); err != nil {
+			return fmt.Errorf("%s decoding of %s failed with %v", strings.ToUpper(format), p, err)
+		}
 	}
 	err = json.Unmarshal(dat, &wixFile)
 	if err != nil {
 		return fmt.Errorf("JSON Unmarshal failed with %v", err)
 	}
+	base, err := json.MarshalIndent(wixFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	if wixFile.base, err = decodeJSON(base); err != nil {
+		return err
+	}
+	wixFile.source = p
+	wixFile.original = original
 	return nil
 }
 
+// Source returns the path of the loaded manifest file, wix.json if none.
+func (wixFile *WixManifest) Source() string {
+	if wixFile.source == "" {
+		return Filenames[0]
+	}
+	return wixFile.source
+}
+
 func (wixFile *WixManifest) check() error {
-	for _, hook := range wixFile.Hooks {
-		switch hook.When {
-		case "install", "uninstall", "":
-		default:
-			return fmt.Errorf(`Invalid "when" value in hook: %s`, hook.When)
-		}
-		switch hook.Impersonate {
-		case "yes", "no":
-		default:
-			return fmt.Errorf(`Invalid "impersonate" value in hook: %s`, hook.Impersonate)
-		}
+	if problems := wixFile.Validate(); len(problems) > 0 {
+		return ValidationError(problems)
 	}
-	for _, shortcut := range wixFile.Shortcuts {
-		switch shortcut.Location {
-		case "program", "desktop":
-		default:
-			return fmt.Errorf(`Invalid "location" value in shortcut: %s`, shortcut.Location)
-		}
+	return nil
+}
+
+// The install scopes of a product.
+const (
+	PerMachine = "per-machine"
+	PerUser    = "per-user"
+	Dual       = "dual"
+)
+
+// Scope returns the install scope of the product, per-machine by default.
+func (wixFile *WixManifest) Scope() string {
+	if wixFile.InstallScope == "" {
+		return PerMachine
 	}
-	if wixFile.NeedGUID() {
-		return fmt.Errorf(`The manifest needs Guid, To update your file automatically run "go-msi set-guid"`)
+	return wixFile.InstallScope
+}
+
+// RegistryRoot returns the registry root of the keys go-msi writes,
+// such as the uninstall entry, for the install scope of the product.
+func (wixFile *WixManifest) RegistryRoot() string {
+	switch wixFile.Scope() {
+	case PerUser:
+		return "HKCU"
+	case Dual:
+		return "HKMU"
 	}
-	return nil
+	return "HKLM"
 }
 
-//SetGuids generates and apply guid values appropriately
+// SetGuids generates and apply guid values appropriately
 func (wixFile *WixManifest) SetGuids(force bool) (bool, error) {
 	updated := false
 	if wixFile.UpgradeCode == "" || force {
@@ -335,23 +538,12 @@ func (wixFile *WixManifest) RewriteFilePaths(out string) error {
 		wixFile.License = path
 	}
 
-	id := 1
-	if err := wixFile.walkDirectories(func(dir Directory) (Directory, error) {
-		dir.ID = id
-		id++
-		return dir, nil
-	}); err != nil {
-		return err
-	}
-	id = 1
 	if err := wixFile.walkFiles(func(file File) (File, error) {
 		path, err := rewrite(out, file.Path)
 		if err != nil {
 			return file, err
 		}
 		file.Path = path
-		file.ID = id
-		id++
 		return file, nil
 	}); err != nil {
 		return err
@@ -377,27 +569,10 @@ func rewrite(out, path string) (string, error) {
 	return filepath.Rel(out, filepath.ToSlash(path))
 }
 
-func validateCompression(wixFile *WixManifest) error {
-	if wixFile.Compression == "" {
-		return nil
-	}
-	compressions := []string{"high", "low", "medium", "mszip", "none"}
-	for _, c := range compressions {
-		if c == wixFile.Compression {
-			return nil
-		}
-	}
-	return fmt.Errorf("invalid compression %q, must be one of %s", wixFile.Compression, strings.Join(compressions, ", "))
-}
-
 // Normalize appropriately fixes some values within the decoded json.
 // It applies defaults values on the wix/msi property to generate the msi package.
 // It applies defaults values on the choco property to generate a nuget package.
 func (wixFile *WixManifest) Normalize() error {
-	if err := validateCompression(wixFile); err != nil {
-		return err
-	}
-
 	if wixFile.Version.Display == "" {
 		wixFile.Version.Display = wixFile.Version.User
 	}
@@ -504,13 +679,44 @@ func (wixFile *WixManifest) Normalize() error {
 		}
 	}
 
+	// Add the files of the source directories
+	if err := wixFile.Directory.harvest(); err != nil {
+		return err
+	}
+
+	wixFile.assignIDs()
+
 	// Bind services to their file component
+	wixFile.PasswordProperties = nil
 	if err := wixFile.walkFiles(func(file File) (File, error) {
-		if file.Service != nil {
-			file.Service.Bin = filepath.Base(file.Path)
-			if file.Service.Start == "delayed" {
-				file.Service.Start = "auto"
-				file.Service.Delayed = true
+		for _, service := range file.AllServices() {
+			service.Bin = file.InstallName()
+			service.StartName, _ = service.accountName()
+			if p := service.Password; p != "" && !contains(wixFile.PasswordProperties, p) {
+				wixFile.PasswordProperties = append(wixFile.PasswordProperties, p)
+			}
+			if r := service.Recovery; r != nil {
+				command, err := escapeHook(r.Command)
+				if err != nil {
+					return file, err
+				}
+				r.CookedCommand = command
+			}
+			if e := service.EventSource; e != nil {
+				if e.Name == "" {
+					e.Name = service.Name
+				}
+				if e.Log == "" {
+					e.Log = "Application"
+				}
+				if e.MessageFile == "" {
+					e.MessageFile = "[#ApplicationFile_" + file.ID + "]"
+				}
+				e.Key = `SYSTEM\CurrentControlSet\Services\EventLog\` + e.Log + `\` + e.Name
+			}
+			if service.Start == "delayed" {
+				service.Start = "auto"
+				service.Delayed = true
 			}
 		}
 		return file, nil
@@ -518,6 +724,11 @@ func (wixFile *WixManifest) Normalize() error {
 		return err
 	}
 
+	wixFile.dataFolders()
+	wixFile.installFolders()
+	wixFile.assignGUIDs()
+	wixFile.assignFeatures()
+
 	// Compute install size
 	var size int64
 	if err := wixFile.walkFiles(func(file File) (File, error) {
@@ -535,14 +746,20 @@ func (wixFile *WixManifest) Normalize() error {
 	return wixFile.check()
 }
 
-func escapeHook(command string) (string, error) {
+// QuoteCommand quotes the executable of a hook command line
+// unless it is already quoted.
+func QuoteCommand(command string) string {
 	cmd := strings.Trim(command, " ")
 	if len(cmd) > 0 && cmd[0] != '"' {
 		words := strings.Split(cmd, " ")
 		cmd = `"` + words[0] + `"` + cmd[len(words[0]):]
 	}
+	return cmd
+}
+
+func escapeHook(command string) (string, error) {
 	buf := &bytes.Buffer{}
-	if err := xml.EscapeText(buf, []byte(cmd)); err != nil {
+	if err := xml.EscapeText(buf, []byte(QuoteCommand(command))); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
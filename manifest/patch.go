@@ -0,0 +1,842 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// patch applies the changes made to the manifest since it was loaded,
+// the difference between base and ours, to the loaded document.
+// The keys unknown to WixManifest and the keys order are kept, json and
+// jsonc documents are patched in place, which also keeps their formatting
+// and comments. The yaml and toml documents are patched in place when
+// only their values change, the other changes reformat them, which is
+// refused when they have comments.
+func (wixFile *WixManifest) patch(ours []byte, format string) ([]byte, error) {
+	oursTree, err := decodeJSON(ours)
+	if err != nil {
+		return nil, err
+	}
+	from, err := FormatOf(wixFile.Source())
+	if err != nil {
+		return nil, err
+	}
+	orig, err := decode(wixFile.original, from)
+	if err != nil {
+		return nil, err
+	}
+	if from == format {
+		switch format {
+		case FormatJSON, FormatJSONC:
+			if out, err := patchText(wixFile.original, wixFile.base, oursTree); err == nil {
+				return out, nil
+			}
+			// the document does not match the manifest closely enough to be
+			// patched in place, fall back to patching its tree.
+		case FormatYAML, FormatTOML:
+			if out, err := patchScalars(wixFile.original, format, orig, wixFile.base, oursTree); err == nil {
+				return out, nil
+			}
+			if hasComments(wixFile.original, format) {
+				return nil, fmt.Errorf("writing %s would drop its comments, change these fields by hand:\n  %s",
+					wixFile.Source(), strings.Join(differences(wixFile.base, oursTree, ""), "\n  "))
+			}
+		}
+	}
+	return encode(patchTree(orig, wixFile.base, oursTree), format)
+}
+
+func equal(a, b interface{}) bool {
+	var x, y bytes.Buffer
+	if writeJSON(&x, a) != nil || writeJSON(&y, b) != nil {
+		return false
+	}
+	return bytes.Equal(x.Bytes(), y.Bytes())
+}
+
+func (o object) index(key string) int {
+	for i, m := range o {
+		if m.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// arrayOp is a step of the edit script turning an array into another.
+type arrayOp struct {
+	kind byte // '=' kept, '~' modified, '-' removed, '+' inserted
+	base int  // index in the base array, for '=', '~' and '-'
+	ours int  // index in the new array, for '=', '~' and '+'
+}
+
+// diffArray returns the edit script turning base into ours, the elements
+// of the longest common subsequence are kept, the other removed and
+// inserted elements are paired as modifications.
+func diffArray(base, ours []interface{}) []arrayOp {
+	n, m := len(base), len(ours)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(base[i], ours[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []arrayOp
+	var removed, inserted []int
+	flush := func() {
+		k := 0
+		for ; k < len(removed) && k < len(inserted); k++ {
+			ops = append(ops, arrayOp{kind: '~', base: removed[k], ours: inserted[k]})
+		}
+		for _, i := range removed[k:] {
+			ops = append(ops, arrayOp{kind: '-', base: i})
+		}
+		for _, j := range inserted[k:] {
+			ops = append(ops, arrayOp{kind: '+', ours: j})
+		}
+		removed, inserted = nil, nil
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && equal(base[i], ours[j]):
+			flush()
+			ops = append(ops, arrayOp{kind: '=', base: i, ours: j})
+			i++
+			j++
+		case j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, i)
+			i++
+		default:
+			inserted = append(inserted, j)
+			j++
+		}
+	}
+	flush()
+	return ops
+}
+
+// patchTree applies the difference between base and ours to orig.
+func patchTree(orig, base, ours interface{}) interface{} {
+	if equal(base, ours) {
+		return orig
+	}
+	switch o := orig.(type) {
+	case object:
+		b, ok1 := base.(object)
+		u, ok2 := ours.(object)
+		if !ok1 || !ok2 {
+			return ours
+		}
+		res := object{}
+		done := map[string]bool{}
+		for _, m := range o {
+			switch {
+			case b.index(m.key) < 0:
+				res = append(res, m) // unknown key
+			case u.index(m.key) >= 0:
+				v := patchTree(m.value, b[b.index(m.key)].value, u[u.index(m.key)].value)
+				res = append(res, member{key: m.key, value: v})
+			}
+			done[m.key] = true
+		}
+		for _, m := range u {
+			if done[m.key] {
+				continue
+			}
+			if k := b.index(m.key); k < 0 || !equal(b[k].value, m.value) {
+				res = append(res, m)
+			}
+		}
+		return res
+	case []interface{}:
+		b, ok1 := base.([]interface{})
+		u, ok2 := ours.([]interface{})
+		if !ok1 || !ok2 || len(b) != len(o) {
+			return ours
+		}
+		res := []interface{}{}
+		for _, op := range diffArray(b, u) {
+			switch op.kind {
+			case '=':
+				res = append(res, o[op.base])
+			case '~':
+				res = append(res, patchTree(o[op.base], b[op.base], u[op.ours]))
+			case '+':
+				res = append(res, u[op.ours])
+			}
+		}
+		return res
+	}
+	return ours
+}
+
+// node is a json value of a document and its offsets.
+type node struct {
+	start, end int
+	members    []jsonMember // of an object
+	elems      []*node      // of an array
+	kind       byte         // '{', '[' or 0 for the scalars
+}
+
+type jsonMember struct {
+	key      string
+	keyStart int
+	value    *node
+}
+
+// parseNodes parses a json document, without comments, into nodes.
+func parseNodes(data []byte) (*node, error) {
+	p := &nodeParser{data: data}
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.skip(); p.i != len(data) {
+		return nil, fmt.Errorf("unexpected data at offset %d", p.i)
+	}
+	return n, nil
+}
+
+type nodeParser struct {
+	data []byte
+	i    int
+}
+
+func (p *nodeParser) skip() {
+	for p.i < len(p.data) && isSpace(p.data[p.i]) {
+		p.i++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func (p *nodeParser) expect(c byte) error {
+	p.skip()
+	if p.i >= len(p.data) || p.data[p.i] != c {
+		return fmt.Errorf("expected %q at offset %d", c, p.i)
+	}
+	p.i++
+	return nil
+}
+
+func (p *nodeParser) value() (*node, error) {
+	p.skip()
+	if p.i >= len(p.data) {
+		return nil, fmt.Errorf("unexpected end of document")
+	}
+	n := &node{start: p.i}
+	switch c := p.data[p.i]; c {
+	case '{', '[':
+		n.kind = c
+		p.i++
+		closing := byte('}')
+		if c == '[' {
+			closing = ']'
+		}
+		for {
+			p.skip()
+			if p.i < len(p.data) && p.data[p.i] == closing {
+				p.i++
+				break
+			}
+			if len(n.members) > 0 || len(n.elems) > 0 {
+				if err := p.expect(','); err != nil {
+					return nil, err
+				}
+				if p.skip(); p.i < len(p.data) && p.data[p.i] == closing {
+					continue // trailing comma
+				}
+			}
+			if c == '[' {
+				e, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				n.elems = append(n.elems, e)
+				continue
+			}
+			k, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			var key string
+			if err := json.Unmarshal(p.data[k.start:k.end], &key); err != nil {
+				return nil, err
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			n.members = append(n.members, jsonMember{key: key, keyStart: k.start, value: v})
+		}
+	case '"':
+		for p.i++; p.i < len(p.data) && p.data[p.i] != '"'; p.i++ {
+			if p.data[p.i] == '\\' {
+				p.i++
+			}
+		}
+		if p.i >= len(p.data) {
+			return nil, fmt.Errorf("unterminated string at offset %d", n.start)
+		}
+		p.i++
+	default:
+		for p.i < len(p.data) && !isSpace(p.data[p.i]) && !strings.ContainsRune(",:]}", rune(p.data[p.i])) {
+			p.i++
+		}
+		if p.i == n.start {
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, p.i)
+		}
+	}
+	n.end = p.i
+	return n, nil
+}
+
+// textEdit replaces the bytes from start to end of a document.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+type textPatcher struct {
+	data  []byte
+	unit  string // indentation unit of the document
+	edits []textEdit
+}
+
+// patchText applies the difference between base and ours to the json
+// document data in place.
+func patchText(data []byte, base, ours interface{}) ([]byte, error) {
+	root, err := parseNodes(StripComments(data))
+	if err != nil {
+		return nil, err
+	}
+	p := &textPatcher{data: data, unit: indentUnit(data)}
+	if err := p.patch(root, base, ours); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(p.edits, func(i, j int) bool {
+		if p.edits[i].start != p.edits[j].start {
+			return p.edits[i].start > p.edits[j].start
+		}
+		return p.edits[i].end > p.edits[j].end
+	})
+	out := append([]byte{}, data...)
+	for _, e := range p.edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+	return out, nil
+}
+
+// indentUnit returns the indentation of the first indented line of data.
+func indentUnit(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// indentAt returns the indentation of the line at offset i.
+func (p *textPatcher) indentAt(i int) string {
+	start := bytes.LastIndexByte(p.data[:i], '\n') + 1
+	end := start
+	for end < len(p.data) && (p.data[end] == ' ' || p.data[end] == '\t') {
+		end++
+	}
+	return string(p.data[start:end])
+}
+
+// encode returns v as json indented for a line indented by indent.
+func (p *textPatcher) encode(v interface{}, indent string) (string, error) {
+	var compact, out bytes.Buffer
+	if err := writeJSON(&compact, v); err != nil {
+		return "", err
+	}
+	if err := json.Indent(&out, compact.Bytes(), indent, p.unit); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (p *textPatcher) replace(n *node, v interface{}) error {
+	text, err := p.encode(v, p.indentAt(n.start))
+	if err != nil {
+		return err
+	}
+	p.edits = append(p.edits, textEdit{start: n.start, end: n.end, text: text})
+	return nil
+}
+
+// multiline tells whether the first child of n starts on its own line.
+func (p *textPatcher) multiline(n *node, first *node) bool {
+	return bytes.IndexByte(p.data[n.start:first.start], '\n') >= 0
+}
+
+// separator returns the text between two items of the container n.
+func (p *textPatcher) separator(n *node, first *node, start int) string {
+	if p.multiline(n, first) {
+		return "\n" + p.indentAt(start)
+	}
+	return " "
+}
+
+func (p *textPatcher) patch(n *node, base, ours interface{}) error {
+	if equal(base, ours) {
+		return nil
+	}
+	switch n.kind {
+	case '{':
+		b, ok1 := base.(object)
+		u, ok2 := ours.(object)
+		if !ok1 || !ok2 {
+			return p.replace(n, ours)
+		}
+		if len(n.members) == 0 {
+			return p.replace(n, ours)
+		}
+		var runs [][2]int // runs of removed members
+		for i, m := range n.members {
+			k := b.index(m.key)
+			if k < 0 {
+				continue // unknown key
+			}
+			j := u.index(m.key)
+			if j < 0 {
+				if len(runs) > 0 && runs[len(runs)-1][1] == i-1 {
+					runs[len(runs)-1][1] = i
+				} else {
+					runs = append(runs, [2]int{i, i})
+				}
+				continue
+			}
+			if err := p.patch(m.value, b[k].value, u[j].value); err != nil {
+				return err
+			}
+		}
+		if len(runs) == 1 && runs[0][0] == 0 && runs[0][1] == len(n.members)-1 {
+			return p.replace(n, ours)
+		}
+		starts := make([]*node, len(n.members))
+		for i, m := range n.members {
+			starts[i] = m.value
+		}
+		p.remove(n, starts, runs, func(i int) int { return n.members[i].keyStart })
+		var added []string
+		last := n.members[len(n.members)-1]
+		indent := p.indentAt(last.keyStart)
+		for _, m := range u {
+			if p.member(n, m.key) != nil {
+				continue
+			}
+			if k := b.index(m.key); k >= 0 && equal(b[k].value, m.value) {
+				continue // not in the document and left unchanged
+			}
+			k, _ := json.Marshal(m.key)
+			v, err := p.encode(m.value, indent)
+			if err != nil {
+				return err
+			}
+			added = append(added, string(k)+": "+v)
+		}
+		if len(added) > 0 {
+			sep := p.separator(n, n.members[0].value, last.keyStart)
+			text := "," + sep + strings.Join(added, ","+sep)
+			p.edits = append(p.edits, textEdit{start: last.value.end, end: last.value.end, text: text})
+		}
+		return nil
+	case '[':
+		b, ok1 := base.([]interface{})
+		u, ok2 := ours.([]interface{})
+		if !ok1 || !ok2 || len(b) != len(n.elems) {
+			return p.replace(n, ours)
+		}
+		if len(n.elems) == 0 || len(u) == 0 {
+			return p.replace(n, ours)
+		}
+		var runs [][2]int
+		var pending []interface{}
+		insert := func(at int) error {
+			if len(pending) == 0 {
+				return nil
+			}
+			var anchor *node
+			if at < len(n.elems) {
+				anchor = n.elems[at]
+			} else {
+				anchor = n.elems[len(n.elems)-1]
+			}
+			indent := p.indentAt(anchor.start)
+			sep := p.separator(n, n.elems[0], anchor.start)
+			texts := make([]string, len(pending))
+			for i, v := range pending {
+				var err error
+				if texts[i], err = p.encode(v, indent); err != nil {
+					return err
+				}
+			}
+			pending = nil
+			if at < len(n.elems) {
+				p.edits = append(p.edits, textEdit{start: anchor.start, end: anchor.start, text: strings.Join(texts, ","+sep) + "," + sep})
+			} else {
+				p.edits = append(p.edits, textEdit{start: anchor.end, end: anchor.end, text: "," + sep + strings.Join(texts, ","+sep)})
+			}
+			return nil
+		}
+		for _, op := range diffArray(b, u) {
+			switch op.kind {
+			case '+':
+				pending = append(pending, u[op.ours])
+				continue
+			case '-':
+				if len(runs) > 0 && runs[len(runs)-1][1] == op.base-1 {
+					runs[len(runs)-1][1] = op.base
+				} else {
+					runs = append(runs, [2]int{op.base, op.base})
+				}
+				continue
+			case '~':
+				if err := p.patch(n.elems[op.base], b[op.base], u[op.ours]); err != nil {
+					return err
+				}
+			}
+			if err := insert(op.base); err != nil {
+				return err
+			}
+		}
+		if len(runs) == 1 && runs[0][0] == 0 && runs[0][1] == len(n.elems)-1 {
+			return p.replace(n, ours)
+		}
+		if err := insert(len(n.elems)); err != nil {
+			return err
+		}
+		p.remove(n, n.elems, runs, func(i int) int { return n.elems[i].start })
+		return nil
+	}
+	return p.replace(n, ours)
+}
+
+// remove removes the runs of items of the container n, values are the
+// nodes of its values and start returns the offset at which an item starts.
+func (p *textPatcher) remove(n *node, values []*node, runs [][2]int, start func(int) int) {
+	for _, r := range runs {
+		if r[0] > 0 {
+			p.edits = append(p.edits, textEdit{start: values[r[0]-1].end, end: values[r[1]].end})
+		} else {
+			p.edits = append(p.edits, textEdit{start: start(r[0]), end: start(r[1] + 1)})
+		}
+	}
+}
+
+func (p *textPatcher) member(n *node, key string) *node {
+	for _, m := range n.members {
+		if m.key == key {
+			return m.value
+		}
+	}
+	return nil
+}
+
+// scalarChange sets the scalar value at path, made of keys and indexes.
+type scalarChange struct {
+	path  []interface{}
+	value interface{}
+	added bool // the key is not in the document
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case object, []interface{}:
+		return false
+	}
+	return true
+}
+
+// scalarChanges returns the changes of the scalar values turning base into
+// ours, as applied to orig by patchTree. It returns false when base and ours
+// differ by more than their scalar values and top level scalar keys.
+func scalarChanges(orig, base, ours interface{}, path []interface{}) ([]scalarChange, bool) {
+	if equal(base, ours) {
+		return nil, true
+	}
+	switch o := orig.(type) {
+	case object:
+		b, ok1 := base.(object)
+		u, ok2 := ours.(object)
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		for _, m := range b {
+			if u.index(m.key) < 0 && o.index(m.key) >= 0 {
+				return nil, false // removed
+			}
+		}
+		var changes []scalarChange
+		for _, m := range u {
+			sub := append(append([]interface{}{}, path...), m.key)
+			k, i := b.index(m.key), o.index(m.key)
+			switch {
+			case k >= 0 && equal(b[k].value, m.value):
+			case i < 0:
+				if len(path) > 0 || !isScalar(m.value) {
+					return nil, false
+				}
+				changes = append(changes, scalarChange{path: sub, value: m.value, added: true})
+			case k >= 0:
+				c, ok := scalarChanges(o[i].value, b[k].value, m.value, sub)
+				if !ok {
+					return nil, false
+				}
+				changes = append(changes, c...)
+			}
+		}
+		return changes, true
+	case []interface{}:
+		b, ok1 := base.([]interface{})
+		u, ok2 := ours.([]interface{})
+		if !ok1 || !ok2 || len(b) != len(o) || len(u) != len(o) {
+			return nil, false
+		}
+		var changes []scalarChange
+		for i := range o {
+			c, ok := scalarChanges(o[i], b[i], u[i], append(append([]interface{}{}, path...), i))
+			if !ok {
+				return nil, false
+			}
+			changes = append(changes, c...)
+		}
+		return changes, true
+	}
+	if !isScalar(ours) {
+		return nil, false
+	}
+	return []scalarChange{{path: path, value: ours}}, true
+}
+
+// setPath returns a copy of tree with the value at path set to value.
+func setPath(tree interface{}, path []interface{}, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	switch t := tree.(type) {
+	case object:
+		key := path[0].(string)
+		res := append(object{}, t...)
+		if i := res.index(key); i >= 0 {
+			res[i].value = setPath(res[i].value, path[1:], value)
+		} else {
+			res = append(res, member{key: key, value: setPath(nil, path[1:], value)})
+		}
+		return res
+	case []interface{}:
+		i := path[0].(int)
+		res := append([]interface{}{}, t...)
+		res[i] = setPath(res[i], path[1:], value)
+		return res
+	}
+	return tree
+}
+
+// equivalent tells whether the trees are equal, regardless of the keys order.
+func equivalent(a, b interface{}) bool {
+	var x, y bytes.Buffer
+	if writeJSON(&x, a) != nil || writeJSON(&y, b) != nil {
+		return false
+	}
+	var u, v interface{}
+	if json.Unmarshal(x.Bytes(), &u) != nil || json.Unmarshal(y.Bytes(), &v) != nil {
+		return false
+	}
+	return reflect.DeepEqual(u, v)
+}
+
+// encodeScalar returns the text of a scalar value in a yaml or toml document.
+func encodeScalar(v interface{}, format string) (string, error) {
+	if format == FormatTOML {
+		var buf bytes.Buffer
+		err := writeTOMLValue(&buf, v)
+		return buf.String(), err
+	}
+	out, err := yaml.Marshal(toYAML(v))
+	return strings.TrimSuffix(string(out), "\n"), err
+}
+
+// patchScalars applies the difference between base and ours to the yaml or
+// toml document data in place, when it only sets scalar values. Each value
+// is searched on the lines of its key, and the document is decoded again to
+// check that only this value changed.
+func patchScalars(data []byte, format string, orig, base, ours interface{}) ([]byte, error) {
+	changes, ok := scalarChanges(orig, base, ours, nil)
+	if !ok {
+		return nil, fmt.Errorf("the changes are not limited to scalar values")
+	}
+	text := string(data)
+	tree := orig
+	for _, c := range changes {
+		value, err := encodeScalar(c.value, format)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := c.path[len(c.path)-1].(string)
+		if !ok {
+			return nil, fmt.Errorf("the array values are not patched in place")
+		}
+		var candidates []string
+		if c.added {
+			candidates, err = addScalar(text, format, key, value)
+		} else {
+			candidates = replaceScalar(text, format, key, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		want := setPath(tree, c.path, c.value)
+		found := false
+		for _, candidate := range candidates {
+			if got, err := decode([]byte(candidate), format); err == nil && equivalent(got, want) {
+				text, tree, found = candidate, want, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the value of %q is not found in the document", key)
+		}
+	}
+	return []byte(text), nil
+}
+
+// replaceScalar returns the texts replacing the value of one of the lines
+// of key with value, before each of what may start a trailing comment.
+func replaceScalar(text, format, key, value string) []string {
+	sep := ":"
+	if format == FormatTOML {
+		sep = "="
+	}
+	k := regexp.QuoteMeta(key)
+	line := regexp.MustCompile(`(?m)^[ \t]*(?:-[ \t]+)*(?:` + k + `|"` + k + `"|'` + k + `')[ \t]*` + sep + `([^\r\n]*)`)
+	var candidates []string
+	for _, loc := range line.FindAllStringSubmatchIndex(text, -1) {
+		start, rest := loc[2], text[loc[2]:loc[3]]
+		// the shortest values first, to keep the comments
+		var ends []int
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '#' && (rest[i-1] == ' ' || rest[i-1] == '\t') {
+				ends = append(ends, len(strings.TrimRight(rest[:i], " \t")))
+			}
+		}
+		ends = append(ends, len(strings.TrimRight(rest, " \t")))
+		for _, end := range ends {
+			candidates = append(candidates, text[:start]+" "+value+text[start+end:])
+		}
+	}
+	return candidates
+}
+
+var (
+	tomlTable   = regexp.MustCompile(`(?m)^[ \t]*\[`)
+	yamlComment = regexp.MustCompile(`(?m)(^|[ \t])#`)
+)
+
+// addScalar returns the text adding key to the top level of the document,
+// at its end for yaml, and before the first table for toml.
+func addScalar(text, format, key, value string) ([]string, error) {
+	if format == FormatTOML {
+		entry := tomlKey(key) + " = " + value + "\n"
+		if loc := tomlTable.FindStringIndex(text); loc != nil {
+			return []string{text[:loc[0]] + entry + text[loc[0]:]}, nil
+		}
+		return []string{strings.TrimRight(text, "\n") + "\n" + entry}, nil
+	}
+	k, err := encodeScalar(key, format)
+	if err != nil {
+		return nil, err
+	}
+	return []string{strings.TrimRight(text, "\n") + "\n" + k + ": " + value + "\n"}, nil
+}
+
+// hasComments tells whether a yaml or toml document may have comments.
+func hasComments(data []byte, format string) bool {
+	if format == FormatTOML {
+		return bytes.IndexByte(data, '#') >= 0
+	}
+	return yamlComment.Match(data)
+}
+
+// differences lists the changes turning base into ours, one per field.
+func differences(base, ours interface{}, path string) []string {
+	if equal(base, ours) {
+		return nil
+	}
+	field := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	text := func(v interface{}) string {
+		var buf bytes.Buffer
+		writeJSON(&buf, v)
+		return buf.String()
+	}
+	var diffs []string
+	switch b := base.(type) {
+	case object:
+		u, ok := ours.(object)
+		if !ok {
+			break
+		}
+		for _, m := range u {
+			if k := b.index(m.key); k >= 0 {
+				diffs = append(diffs, differences(b[k].value, m.value, field(m.key))...)
+			} else {
+				diffs = append(diffs, field(m.key)+" = "+text(m.value))
+			}
+		}
+		for _, m := range b {
+			if u.index(m.key) < 0 {
+				diffs = append(diffs, field(m.key)+" removed")
+			}
+		}
+		return diffs
+	case []interface{}:
+		u, ok := ours.([]interface{})
+		if !ok {
+			break
+		}
+		for _, op := range diffArray(b, u) {
+			switch op.kind {
+			case '~':
+				diffs = append(diffs, differences(b[op.base], u[op.ours], fmt.Sprintf("%s[%d]", path, op.ours))...)
+			case '+':
+				diffs = append(diffs, fmt.Sprintf("%s[%d] inserted = %s", path, op.ours, text(u[op.ours])))
+			case '-':
+				diffs = append(diffs, fmt.Sprintf("%s[%d] removed", path, op.base))
+			}
+		}
+		return diffs
+	}
+	return []string{path + " = " + text(ours)}
+}
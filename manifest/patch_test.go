@@ -0,0 +1,204 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tree(t *testing.T, data, format string) interface{} {
+	v, err := decode([]byte(data), format)
+	if err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	return v
+}
+
+// patchCases are the documents patched by patchText and patchTree, with
+// the base and ours manifests, and the documents they produce.
+var patchCases = []struct {
+	name, doc, base, ours string
+	text, tree            string // patchText and patchTree results
+}{
+	{
+		name: "reordered keys",
+		doc:  `{"b": "1", "a": "2"}`,
+		base: `{"a": "2", "b": "1"}`,
+		ours: `{"a": "3", "b": "1"}`,
+		text: `{"b": "1", "a": "3"}`,
+		tree: `{"b": "1", "a": "3"}`,
+	},
+	{
+		name: "unknown keys",
+		doc:  `{"x-note": "kept", "a": "1"}`,
+		base: `{"a": "1", "b": ""}`,
+		ours: `{"a": "1", "b": "2"}`,
+		text: `{"x-note": "kept", "a": "1", "b": "2"}`,
+		tree: `{"x-note": "kept", "a": "1", "b": "2"}`,
+	},
+	{
+		name: "removed key",
+		doc:  `{"a": "1", "b": "2", "c": "3"}`,
+		base: `{"a": "1", "b": "2", "c": "3"}`,
+		ours: `{"a": "1", "c": "3"}`,
+		text: `{"a": "1", "c": "3"}`,
+		tree: `{"a": "1", "c": "3"}`,
+	},
+	{
+		name: "array insert",
+		doc:  "{\"files\": [\n  {\"path\": \"a\"},\n  {\"path\": \"c\"}\n]}",
+		base: `{"files": [{"path": "a"}, {"path": "c"}]}`,
+		ours: `{"files": [{"path": "a"}, {"path": "b"}, {"path": "c"}, {"path": "d"}]}`,
+		text: "{\"files\": [\n  {\"path\": \"a\"},\n  {\n    \"path\": \"b\"\n  },\n  {\"path\": \"c\"},\n  {\n    \"path\": \"d\"\n  }\n]}",
+		tree: `{"files": [{"path": "a"}, {"path": "b"}, {"path": "c"}, {"path": "d"}]}`,
+	},
+	{
+		name: "array remove",
+		doc:  `{"files": [{"path": "a"}, {"path": "b"}, {"path": "c"}]}`,
+		base: `{"files": [{"path": "a"}, {"path": "b"}, {"path": "c"}]}`,
+		ours: `{"files": [{"path": "a"}, {"path": "c"}]}`,
+		text: `{"files": [{"path": "a"}, {"path": "c"}]}`,
+		tree: `{"files": [{"path": "a"}, {"path": "c"}]}`,
+	},
+	{
+		name: "array modify keeps the unknown keys",
+		doc:  `{"files": [{"path": "a", "x-note": "kept"}, {"path": "b"}]}`,
+		base: `{"files": [{"path": "a"}, {"path": "b"}]}`,
+		ours: `{"files": [{"path": "z"}, {"path": "b"}]}`,
+		text: `{"files": [{"path": "z", "x-note": "kept"}, {"path": "b"}]}`,
+		tree: `{"files": [{"path": "z", "x-note": "kept"}, {"path": "b"}]}`,
+	},
+	{
+		name: "jsonc trailing commas and comments",
+		doc:  "{\n  // the product\n  \"product\": \"hello\", /* name */\n  \"files\": [\"a\",],\n}",
+		base: `{"product": "hello", "files": ["a"]}`,
+		ours: `{"product": "bye", "files": ["a", "b"]}`,
+		text: "{\n  // the product\n  \"product\": \"bye\", /* name */\n  \"files\": [\"a\", \"b\",],\n}",
+		tree: `{"product": "bye", "files": ["a", "b"]}`,
+	},
+}
+
+func TestPatchText(t *testing.T) {
+	for _, c := range patchCases {
+		out, err := patchText([]byte(c.doc), tree(t, c.base, FormatJSON), tree(t, c.ours, FormatJSON))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if string(out) != c.text {
+			t.Errorf("%s: patchText =\n%s\nwant\n%s", c.name, out, c.text)
+		}
+	}
+}
+
+func TestPatchTree(t *testing.T) {
+	for _, c := range patchCases {
+		got := patchTree(tree(t, c.doc, FormatJSONC), tree(t, c.base, FormatJSON), tree(t, c.ours, FormatJSON))
+		if want := tree(t, c.tree, FormatJSON); !equal(got, want) {
+			out, _ := encodeJSON(got)
+			t.Errorf("%s: patchTree =\n%s\nwant\n%s", c.name, out, c.tree)
+		}
+	}
+}
+
+func TestPatchScalars(t *testing.T) {
+	for _, c := range []struct {
+		name, format, doc, base, ours string
+		out                           string // empty when not patched in place
+	}{
+		{
+			name:   "yaml value",
+			format: FormatYAML,
+			doc:    "# the product\nproduct: hello # name\nupgrade-code: \"\"\n",
+			base:   `{"product": "hello", "upgrade-code": ""}`,
+			ours:   `{"product": "hello", "upgrade-code": "ABC-123"}`,
+			out:    "# the product\nproduct: hello # name\nupgrade-code: ABC-123\n",
+		},
+		{
+			name:   "yaml added key",
+			format: FormatYAML,
+			doc:    "# the product\nproduct: hello\n",
+			base:   `{"product": "hello", "upgrade-code": ""}`,
+			ours:   `{"product": "hello", "upgrade-code": "ABC-123"}`,
+			out:    "# the product\nproduct: hello\nupgrade-code: ABC-123\n",
+		},
+		{
+			name:   "yaml value in a sequence",
+			format: FormatYAML,
+			doc:    "files:\n- path: a # first\n  name: x\n- path: b\n  name: x\n",
+			base:   `{"files": [{"path": "a", "name": "x"}, {"path": "b", "name": "x"}]}`,
+			ours:   `{"files": [{"path": "a", "name": "x"}, {"path": "b", "name": "y"}]}`,
+			out:    "files:\n- path: a # first\n  name: x\n- path: b\n  name: \"y\"\n",
+		},
+		{
+			name:   "toml values",
+			format: FormatTOML,
+			doc:    "# the product\nproduct = \"hello\"\n\n[info]\ncomments = \"hi\" # shown\n",
+			base:   `{"product": "hello", "upgrade-code": "", "info": {"comments": "hi"}}`,
+			ours:   `{"product": "hello", "upgrade-code": "ABC-123", "info": {"comments": "a # b"}}`,
+			out:    "# the product\nproduct = \"hello\"\n\nupgrade-code = \"ABC-123\"\n[info]\ncomments = \"a # b\" # shown\n",
+		},
+		{
+			name:   "inserted array element",
+			format: FormatYAML,
+			doc:    "files:\n- path: a\n",
+			base:   `{"files": [{"path": "a"}]}`,
+			ours:   `{"files": [{"path": "a"}, {"path": "b"}]}`,
+		},
+	} {
+		orig := tree(t, c.doc, c.format)
+		out, err := patchScalars([]byte(c.doc), c.format, orig, tree(t, c.base, FormatJSON), tree(t, c.ours, FormatJSON))
+		if c.out == "" {
+			if err == nil {
+				t.Errorf("%s: patched in place as\n%s", c.name, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if string(out) != c.out {
+			t.Errorf("%s: patchScalars =\n%s\nwant\n%s", c.name, out, c.out)
+		}
+	}
+}
+
+func TestWriteCommentedYAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-msi-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "wix.yaml")
+	doc := "# the product\nproduct: hello\ncompany: mh-cbon # the company\nfiles:\n- path: a.exe\n"
+	if err := ioutil.WriteFile(p, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wixFile := &WixManifest{}
+	if err := wixFile.Load(p); err != nil {
+		t.Fatal(err)
+	}
+	wixFile.UpgradeCode = "ABC-123"
+	if err := wixFile.Write(p); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := doc + "upgrade-code: ABC-123\n"; string(b) != want {
+		t.Errorf("wix.yaml =\n%s\nwant\n%s", b, want)
+	}
+
+	wixFile.Files = append(wixFile.Files, File{Path: "b.exe"})
+	err = wixFile.Write(p)
+	if err == nil || !strings.Contains(err.Error(), `files[1] inserted = {"path":"b.exe"}`) {
+		t.Errorf("error = %v, want the inserted file listed", err)
+	}
+	if after, _ := ioutil.ReadFile(p); string(after) != string(b) {
+		t.Errorf("wix.yaml is rewritten as\n%s", after)
+	}
+}
@@ -0,0 +1,118 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ProgramFiles is the root of install-dir for the program files
+// folder of the architecture of the package.
+const ProgramFiles = "ProgramFiles"
+
+// StandardFolders lists the msi directories usable as roots, besides
+// the properties set by a registry search.
+var StandardFolders = []string{
+	"AdminToolsFolder", "AppDataFolder", "CommonAppDataFolder", "CommonFiles64Folder",
+	"CommonFilesFolder", "DesktopFolder", "FavoritesFolder", "FontsFolder",
+	"LocalAppDataFolder", "MyPicturesFolder", "PersonalFolder", "ProgramFiles64Folder",
+	"ProgramFilesFolder", "ProgramMenuFolder", "SendToFolder", "StartMenuFolder",
+	"StartupFolder", "System16Folder", "System64Folder", "SystemFolder",
+	"TempFolder", "TemplateFolder", "WindowsFolder", "WindowsVolume",
+}
+
+// InstallFolder is a directory of the path of the install directory.
+type InstallFolder struct {
+	ID   string
+	Name string
+}
+
+var installDir = regexp.MustCompile(`^\[([^\]]*)\](.*)$`)
+
+// parseInstallDir splits an install-dir such as [ProgramFiles]\Company\Product
+// into its root and the names of its directories.
+func parseInstallDir(dir string) (string, []string, error) {
+	m := installDir.FindStringSubmatch(dir)
+	if m == nil {
+		return "", nil, fmt.Errorf(`invalid install directory %q, must be of the form [Root]\Name`, dir)
+	}
+	return m[1], DataPath(m[2]), nil
+}
+
+// installDirectory returns the install-dir of the manifest,
+// [ProgramFiles]\<product> by default.
+func (wixFile *WixManifest) installDirectory() string {
+	if wixFile.InstallDir == "" {
+		return `[` + ProgramFiles + `]\` + wixFile.Product
+	}
+	return wixFile.InstallDir
+}
+
+// installFolders sets the root and the directories of the install directory
+// and the roots to declare, an invalid install-dir is left to Validate.
+func (wixFile *WixManifest) installFolders() {
+	root, names, err := parseInstallDir(wixFile.installDirectory())
+	if err != nil || len(names) == 0 {
+		root, names = ProgramFiles, []string{wixFile.Product}
+	}
+	wixFile.InstallRoot, wixFile.InstallFolders = root, nil
+	for i, name := range names {
+		id := fmt.Sprintf("InstallFolder%d", i+1)
+		if i == len(names)-1 {
+			id = "INSTALLDIR"
+		}
+		wixFile.InstallFolders = append(wixFile.InstallFolders, InstallFolder{ID: id, Name: name})
+	}
+
+	wixFile.RootFolders = nil
+	declared := map[string]bool{ProgramFiles: true, "INSTALLDIR": true, "ProgramMenuFolder": true, "DesktopFolder": true}
+	roots := []string{root}
+	for _, d := range wixFile.Directories {
+		roots = append(roots, d.Root)
+	}
+	for _, f := range wixFile.DataFolders {
+		roots = append(roots, f.ID)
+	}
+	for _, r := range roots {
+		if r != "" && !declared[r] {
+			declared[r] = true
+			wixFile.RootFolders = append(wixFile.RootFolders, r)
+		}
+	}
+}
+
+// assignGUIDs sets the component guid of the files installed below a root
+// defined by a property, as the guid of their components cannot be generated
+// from their path. The guids derive from the upgrade code and the install path.
+func (wixFile *WixManifest) assignGUIDs() {
+	namespace, err := uuid.Parse(wixFile.UpgradeCode)
+	if err != nil {
+		namespace = uuid.Nil
+	}
+	var walk func(dir *Directory, target string, standard bool)
+	walk = func(dir *Directory, target string, standard bool) {
+		for i := range dir.Files {
+			f := &dir.Files[i]
+			f.GUID = ""
+			if !standard {
+				key := strings.ToLower(target + `\` + f.InstallName())
+				f.GUID = strings.ToUpper(uuid.NewSHA1(namespace, []byte(key)).String())
+			}
+		}
+		for i := range dir.Directories {
+			d := &dir.Directories[i]
+			t, s := target+`\`+d.Name, standard
+			if d.Root != "" {
+				t, s = d.Root+`\`+d.Name, contains(StandardFolders, d.Root)
+			}
+			walk(d, t, s)
+		}
+	}
+	target := wixFile.InstallRoot
+	for _, f := range wixFile.InstallFolders {
+		target += `\` + f.Name
+	}
+	walk(&wixFile.Directory, target, wixFile.InstallRoot == ProgramFiles || contains(StandardFolders, wixFile.InstallRoot))
+}
@@ -0,0 +1,114 @@
+package manifest
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Schema returns the json schema of the manifest, generated from the
+// WixManifest struct and the enum, default and desc tags of its fields.
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{definitions: object{}, defined: map[reflect.Type]bool{}}
+	properties := g.properties(reflect.TypeOf(WixManifest{}))
+	doc := object{
+		{key: "$schema", value: "http://json-schema.org/draft-07/schema#"},
+		{key: "title", value: "go-msi wix manifest"},
+		{key: "type", value: "object"},
+		{key: "properties", value: properties},
+		{key: "definitions", value: g.definitions},
+	}
+	return encodeJSON(doc)
+}
+
+type schemaGenerator struct {
+	definitions object
+	defined     map[reflect.Type]bool
+}
+
+// properties returns the schemas of the json fields of the struct t,
+// the fields of the embedded structs are inlined.
+func (g *schemaGenerator) properties(t reflect.Type) object {
+	properties := object{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			properties = append(properties, g.properties(f.Type)...)
+			continue
+		}
+		properties = append(properties, member{key: name, value: g.field(f)})
+	}
+	return properties
+}
+
+// field returns the schema of the struct field f.
+func (g *schemaGenerator) field(f reflect.StructField) object {
+	s := g.schema(f.Type)
+	var annotations object
+	if desc := f.Tag.Get("desc"); desc != "" {
+		annotations = append(annotations, member{key: "description", value: desc})
+	}
+	if enum := f.Tag.Get("enum"); enum != "" {
+		values := []interface{}{}
+		for _, v := range strings.Split(enum, ",") {
+			values = append(values, v)
+		}
+		annotations = append(annotations, member{key: "enum", value: values})
+	}
+	if def := f.Tag.Get("default"); def != "" {
+		var value interface{} = def
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			value = json.Number(def)
+		case reflect.Bool:
+			value = def == "true"
+		}
+		annotations = append(annotations, member{key: "default", value: value})
+	}
+	if len(annotations) == 0 {
+		return s
+	}
+	if s.index("$ref") >= 0 {
+		// the keywords next to $ref are ignored
+		return append(annotations, member{key: "allOf", value: []interface{}{s}})
+	}
+	return append(s[:1:1], append(annotations, s[1:]...)...)
+}
+
+// schema returns the schema of the type t, the structs are
+// referenced from the definitions.
+func (g *schemaGenerator) schema(t reflect.Type) object {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		if !g.defined[t] {
+			g.defined[t] = true
+			i := len(g.definitions)
+			g.definitions = append(g.definitions, member{key: t.Name()})
+			properties := g.properties(t)
+			g.definitions[i].value = object{
+				{key: "type", value: "object"},
+				{key: "properties", value: properties},
+			}
+		}
+		return object{{key: "$ref", value: "#/definitions/" + t.Name()}}
+	case reflect.Slice, reflect.Array:
+		return object{{key: "type", value: "array"}, {key: "items", value: g.schema(t.Elem())}}
+	case reflect.Bool:
+		return object{{key: "type", value: "boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{{key: "type", value: "integer"}}
+	case reflect.Float32, reflect.Float64:
+		return object{{key: "type", value: "number"}}
+	}
+	return object{{key: "type", value: "string"}}
+}
@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSchemaFields checks the schema lists the json fields of the
+// manifest structs, with the values of their enum tags.
+func TestSchemaFields(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties  map[string]map[string]interface{} `json:"properties"`
+		Definitions map[string]struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	checked := map[reflect.Type]bool{}
+	var check func(typ reflect.Type, properties map[string]map[string]interface{}, path string)
+	check = func(typ reflect.Type, properties map[string]map[string]interface{}, path string) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if f.PkgPath != "" || name == "-" {
+				continue
+			}
+			if f.Anonymous && name == "" {
+				check(f.Type, properties, path)
+				continue
+			}
+			p := strings.TrimPrefix(path+"."+name, ".")
+			property, ok := properties[name]
+			if !ok {
+				t.Errorf("%s: missing from the schema", p)
+				continue
+			}
+			if enum := f.Tag.Get("enum"); enum != "" {
+				var values []string
+				enums, _ := property["enum"].([]interface{})
+				for _, v := range enums {
+					values = append(values, v.(string))
+				}
+				if got := strings.Join(values, ","); got != enum {
+					t.Errorf("%s: schema enum %q, want %q", p, got, enum)
+				}
+			}
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !checked[ft] {
+				checked[ft] = true
+				def, ok := schema.Definitions[ft.Name()]
+				if !ok {
+					t.Errorf("%s: missing definition %s", p, ft.Name())
+					continue
+				}
+				check(ft, def.Properties, p)
+			}
+		}
+	}
+	check(reflect.TypeOf(WixManifest{}), schema.Properties, "")
+
+	// the enums the templates rely on
+	for _, e := range []struct {
+		definition, property, enum string
+	}{
+		{"Hook", "when", "install,uninstall"},
+		{"Shortcut", "location", "program,desktop"},
+		{"Environment", "part", "all,first,last"},
+		{"RegistryValue", "type", "string,integer,binary,expandable,multiString"},
+		{"", "compression", "high,low,medium,mszip,none"},
+	} {
+		properties := schema.Properties
+		if e.definition != "" {
+			properties = schema.Definitions[e.definition].Properties
+		}
+		property, ok := properties[e.property]
+		if !ok {
+			t.Errorf("%s.%s: missing from the schema", e.definition, e.property)
+			continue
+		}
+		values, _ := property["enum"].([]interface{})
+		var got []string
+		for _, v := range values {
+			got = append(got, v.(string))
+		}
+		if strings.Join(got, ",") != e.enum {
+			t.Errorf("%s.%s: schema enum %v, want %s", e.definition, e.property, got, e.enum)
+		}
+	}
+}
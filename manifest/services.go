@@ -0,0 +1,54 @@
+package manifest
+
+import "strings"
+
+// builtinAccounts maps the built-in service accounts, which have no
+// password, to the name the service control manager expects.
+var builtinAccounts = map[string]string{
+	"localsystem":                 "LocalSystem",
+	`nt authority\system`:         "LocalSystem",
+	"localservice":                `NT AUTHORITY\LocalService`,
+	`nt authority\localservice`:   `NT AUTHORITY\LocalService`,
+	"networkservice":              `NT AUTHORITY\NetworkService`,
+	`nt authority\networkservice`: `NT AUTHORITY\NetworkService`,
+}
+
+// virtualAccount is the domain of the virtual accounts of the services.
+const virtualAccount = `NT SERVICE\`
+
+// accountName returns the name of the account of the service, as the
+// service control manager expects it, and whether the account has no password:
+// the built-in accounts, the virtual accounts and the managed service accounts.
+func (s Service) accountName() (string, bool) {
+	account := s.Account
+	if account == "" {
+		account = "LocalSystem"
+	}
+	if name, ok := builtinAccounts[strings.ToLower(account)]; ok {
+		return name, true
+	}
+	if strings.HasPrefix(strings.ToUpper(account), virtualAccount) || strings.HasSuffix(account, "$") {
+		return account, true
+	}
+	if !strings.ContainsAny(account, `\@`) {
+		// a local user
+		return `.\` + account, false
+	}
+	return account, false
+}
+
+// Actions returns the recovery actions on the first, second and subsequent
+// failures, named as the WiX util:ServiceConfig element names them.
+func (r Recovery) Actions() []string {
+	var actions []string
+	for _, a := range []string{r.First, r.Second, r.Subsequent} {
+		switch a {
+		case "":
+			a = "none"
+		case "run-command":
+			a = "runCommand"
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
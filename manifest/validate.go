@@ -0,0 +1,534 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Problem is an invalid value of a manifest.
+type Problem struct {
+	Path    string `json:"path"`             // json path of the value, such as hooks[0].when
+	Line    int    `json:"line,omitempty"`   // position of the value in json and jsonc files
+	Column  int    `json:"column,omitempty"` // in bytes
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// ValidationError lists the problems of a manifest.
+type ValidationError []Problem
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, p := range e {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RegistryRoots lists the valid roots of the registry paths.
+var RegistryRoots = []string{"HKCR", "HKCU", "HKLM", "HKMU", "HKU"}
+
+var propertyID = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// Validate checks the manifest and returns all its problems,
+// the paths of the files are relative to the current directory.
+func (wixFile *WixManifest) Validate() []Problem {
+	v := &validator{features: map[string]bool{}, properties: map[string]bool{}, directories: map[string]bool{}, eventSources: map[string]string{}, scope: wixFile.Scope()}
+	for _, prop := range wixFile.Properties {
+		v.properties[prop.ID] = true
+	}
+	for _, id := range append([]string{"TARGETDIR", "INSTALLDIR"}, StandardFolders...) {
+		v.directories[id] = true
+	}
+	v.enums(reflect.ValueOf(wixFile).Elem())
+	v.featureTree(wixFile.Features, "features")
+
+	if wixFile.Product == "" {
+		v.add("product", "must not be empty")
+	}
+	if wixFile.Company == "" {
+		v.add("company", "must not be empty")
+	}
+	if wixFile.NeedGUID() {
+		v.add("upgrade-code", `The manifest needs Guid, To update your file automatically run "go-msi set-guid"`)
+	}
+	for _, f := range []struct{ path, value string }{
+		{"license", wixFile.License},
+		{"banner", wixFile.Banner},
+		{"dialog", wixFile.Dialog},
+		{"icon", wixFile.Icon},
+	} {
+		v.exists(f.path, f.value)
+	}
+
+	if root, names, err := parseInstallDir(wixFile.installDirectory()); err != nil {
+		v.add("install-dir", err.Error())
+	} else {
+		if len(names) == 0 {
+			v.add("install-dir", "must name a directory below its root")
+		}
+		v.pathNames("install-dir", wixFile.InstallDir, names)
+		if root != ProgramFiles {
+			v.root("install-dir", root)
+		}
+	}
+
+	services := map[string]string{}
+	targets := map[string]string{}
+	v.feature("feature", wixFile.Feature)
+	v.source("source", wixFile.SourceDir)
+	if wixFile.Directory.ID != "" {
+		v.add("id", "the id of the install directory is INSTALLDIR")
+	}
+	if wixFile.Root != "" {
+		v.add("root", "the root of the install directory is set by install-dir")
+	}
+	v.files(wixFile.Files, wixFile.Directories, "", "", services, targets)
+	v.references(reflect.ValueOf(wixFile).Elem())
+
+	for i, shortcut := range wixFile.Shortcuts {
+		if shortcut.Location == "" {
+			v.add(fmt.Sprintf("shortcuts[%d].location", i), "must not be empty")
+		}
+		if shortcut.WDir != "" && !v.directories[shortcut.WDir] && !v.properties[shortcut.WDir] {
+			v.add(fmt.Sprintf("shortcuts[%d].wdir", i), fmt.Sprintf("unknown directory %q, must be the id of a directory or a property", shortcut.WDir))
+		}
+		v.feature(fmt.Sprintf("shortcuts[%d].feature", i), shortcut.Feature)
+	}
+	for i, reg := range wixFile.Registries {
+		v.registry(fmt.Sprintf("registries[%d].path", i), reg.Path)
+		if v.scope == PerUser && strings.HasPrefix(reg.Path, `HKLM\`) {
+			v.add(fmt.Sprintf("registries[%d].path", i), "per-user installs cannot write to HKLM, use HKCU or HKMU")
+		}
+		v.feature(fmt.Sprintf("registries[%d].feature", i), reg.Feature)
+	}
+	for i, prop := range wixFile.Properties {
+		p := fmt.Sprintf("properties[%d]", i)
+		if !propertyID.MatchString(prop.ID) {
+			v.add(p+".id", fmt.Sprintf("malformed property id %q, it must start with a letter or an underscore followed by letters, digits, underscores or periods", prop.ID))
+		}
+		if prop.Registry != nil {
+			v.registry(p+".registry.path", prop.Registry.Path)
+		}
+	}
+	for i, env := range wixFile.Environments {
+		p := fmt.Sprintf("environments[%d]", i)
+		for _, f := range []struct{ name, value string }{
+			{"name", env.Name},
+			{"value", env.Value},
+			{"permanent", env.Permanent},
+			{"system", env.System},
+			{"action", env.Action},
+			{"part", env.Part},
+		} {
+			if f.value == "" && !(f.name == "value" && env.Action == "remove") {
+				v.add(p+"."+f.name, "must not be empty")
+			}
+		}
+		v.feature(p+".feature", env.Feature)
+	}
+	for i, c := range wixFile.ServiceControls {
+		p := fmt.Sprintf("service-controls[%d]", i)
+		if c.Name == "" {
+			v.add(p+".name", "must not be empty")
+		} else if other, ok := services[strings.ToLower(c.Name)]; ok {
+			v.add(p+".name", fmt.Sprintf("the service %q is installed by %s, which controls it", c.Name, strings.TrimSuffix(other, ".name")))
+		}
+		if c.Start == "" && c.Stop == "" && c.Remove == "" {
+			v.add(p, "must start, stop or remove the service")
+		}
+		if v.scope == PerUser {
+			v.add(p, "per-user installs cannot control services")
+		}
+		v.feature(p+".feature", c.Feature)
+	}
+	dataDirs := map[string]string{}
+	for i, d := range wixFile.DataDirectories {
+		p := fmt.Sprintf("data-directories[%d]", i)
+		names := DataPath(d.Path)
+		if len(names) == 0 {
+			v.add(p+".path", "must not be empty")
+		}
+		v.pathNames(p+".path", d.Path, names)
+		root := d.Root
+		if root == "" {
+			root = "program-data"
+		}
+		key := strings.ToLower(root + "/" + strings.Join(names, "/"))
+		if other, ok := dataDirs[key]; ok && len(names) > 0 {
+			v.add(p+".path", fmt.Sprintf("duplicate data directory %q, already declared by %s", d.Path, other))
+		}
+		dataDirs[key] = p + ".path"
+		for j, perm := range d.Permissions {
+			if perm.User == "" {
+				v.add(fmt.Sprintf("%s.permissions[%d].user", p, j), "must not be empty")
+			}
+		}
+		v.feature(p+".feature", d.Feature)
+	}
+
+	v.locate(wixFile.original, wixFile.Source())
+	return v.problems
+}
+
+type validator struct {
+	problems     []Problem
+	features     map[string]bool   // ids of the features
+	properties   map[string]bool   // ids of the properties
+	directories  map[string]bool   // ids of the directories
+	eventSources map[string]string // paths of the event sources by log and name
+	scope        string
+}
+
+func (v *validator) add(path, message string) {
+	v.problems = append(v.problems, Problem{Path: path, Message: message})
+}
+
+// strings calls f with the json path, the field and the value of the
+// string fields of val.
+func (v *validator) strings(val reflect.Value, path string, f func(path string, field reflect.StructField, value string)) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if !val.IsNil() {
+			v.strings(val.Elem(), path, f)
+		}
+	case reflect.Slice:
+		for i := 0; i < val.Len(); i++ {
+			v.strings(val.Index(i), fmt.Sprintf("%s[%d]", path, i), f)
+		}
+	case reflect.Struct:
+		t := val.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.PkgPath != "" || name == "-" {
+				continue
+			}
+			p := path
+			if !field.Anonymous || name != "" {
+				p = strings.TrimPrefix(path+"."+name, ".")
+			}
+			if field.Type.Kind() == reflect.String {
+				f(p, field, val.Field(i).String())
+				continue
+			}
+			v.strings(val.Field(i), p, f)
+		}
+	}
+}
+
+// enums checks the string fields tagged with enum.
+func (v *validator) enums(val reflect.Value) {
+	v.strings(val, "", func(path string, field reflect.StructField, value string) {
+		if enum := field.Tag.Get("enum"); enum != "" {
+			values := strings.Split(enum, ",")
+			if value != "" && !contains(values, value) {
+				v.add(path, fmt.Sprintf("invalid value %q, must be one of %s", value, strings.Join(values, ", ")))
+			}
+		}
+	})
+}
+
+// MSIProperties lists the installer properties commonly formatted
+// next to a path, such as [ProductName].exe.
+var MSIProperties = []string{
+	"ComputerName", "Date", "LogonUser", "Manufacturer", "ProductCode",
+	"ProductLanguage", "ProductName", "ProductVersion", "SourceDir", "Time", "UpgradeCode",
+}
+
+// directoryReference matches the [id] references followed by a path.
+var directoryReference = regexp.MustCompile(`\[([A-Za-z_][A-Za-z0-9_.]*)\][\w.-]`)
+
+// references checks the [id] references followed by a path, such as
+// [INSTALLDIR]hello.exe, refer to a directory or a property.
+func (v *validator) references(val reflect.Value) {
+	v.strings(val, "", func(path string, field reflect.StructField, value string) {
+		for _, m := range directoryReference.FindAllStringSubmatch(value, -1) {
+			if id := m[1]; !v.directories[id] && !v.properties[id] && !contains(MSIProperties, id) {
+				v.add(path, fmt.Sprintf("unknown directory %q in [%s], must be the id of a directory or a property", id, id))
+			}
+		}
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) exists(path, file string) {
+	if file == "" {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		v.add(path, fmt.Sprintf("file %q not found", file))
+	}
+}
+
+// files checks the files of a directory, the install paths of the files
+// must differ case insensitively and the service names must be unique.
+func (v *validator) files(files []File, dirs []Directory, p, target string, services, targets map[string]string) {
+	for i, file := range files {
+		fp := fmt.Sprintf("%sfiles[%d]", p, i)
+		v.feature(fp+".feature", file.Feature)
+		if file.Path == "" {
+			v.add(fp+".path", "must not be empty")
+		} else {
+			v.exists(fp+".path", file.Path)
+			t := path.Join(target, file.InstallName())
+			if other, ok := targets[strings.ToLower(t)]; ok {
+				v.add(fp+".path", fmt.Sprintf("installs to %s, as %s does", t, other))
+			} else {
+				targets[strings.ToLower(t)] = fp + ".path"
+			}
+		}
+		if strings.ContainsAny(file.Name, `\/:*?"<>|`) {
+			v.add(fp+".name", fmt.Sprintf(`invalid file name %q, it must not contain \ / : * ? " < > |`, file.Name))
+		}
+		if file.Service != nil {
+			v.service(fp+".service", *file.Service, services)
+		}
+		for j, service := range file.Services {
+			v.service(fmt.Sprintf("%s.services[%d]", fp, j), service, services)
+		}
+	}
+	for i, dir := range dirs {
+		dp := fmt.Sprintf("%sdirectories[%d]", p, i)
+		if dir.ID != "" {
+			switch {
+			case !propertyID.MatchString(dir.ID) || len(dir.ID) > 72:
+				v.add(dp+".id", fmt.Sprintf("malformed directory id %q, it must start with a letter or an underscore followed by up to 71 letters, digits, underscores or periods", dir.ID))
+			case v.directories[dir.ID] || v.properties[dir.ID]:
+				v.add(dp+".id", fmt.Sprintf("duplicate directory id %q, already used by a directory or a property", dir.ID))
+			}
+			v.directories[dir.ID] = true
+		}
+		t := path.Join(target, dir.Name)
+		switch {
+		case dir.Root != "" && p != "":
+			v.add(dp+".root", "only the top level directories can have a root")
+		case dir.Root != "":
+			v.root(dp+".root", dir.Root)
+			t = path.Join("["+dir.Root+"]", dir.Name)
+		case dir.Name == "":
+			v.add(dp+".name", "must not be empty")
+		}
+		v.feature(dp+".feature", dir.Feature)
+		v.source(dp+".source", dir.SourceDir)
+		v.files(dir.Files, dir.Directories, dp+".", t, services, targets)
+	}
+}
+
+// service checks a service, the service names must be unique.
+func (v *validator) service(path string, s Service, services map[string]string) {
+	if v.scope == PerUser {
+		v.add(path, "per-user installs cannot install services")
+	}
+	name := strings.ToLower(s.Name)
+	if s.Name == "" {
+		v.add(path+".name", "must not be empty")
+	} else if other, ok := services[name]; ok {
+		v.add(path+".name", fmt.Sprintf("duplicate service name %q, already used by %s", s.Name, other))
+	} else {
+		services[name] = path + ".name"
+	}
+	v.account(path, s)
+	v.serviceConfig(path, s)
+	if e := s.EventSource; e != nil {
+		v.eventSource(path+".event-source", s.Name, *e)
+	}
+}
+
+// eventSource checks the event log sources are unique in their log.
+func (v *validator) eventSource(path, service string, e EventSource) {
+	name, log := e.Name, e.Log
+	if name == "" {
+		name = service
+	}
+	if log == "" {
+		log = "Application"
+	}
+	if strings.Contains(name, `\`) {
+		v.add(path+".name", fmt.Sprintf(`invalid event source %q, it must not contain \`, name))
+	}
+	if strings.Contains(log, `\`) {
+		v.add(path+".log", fmt.Sprintf(`invalid event log %q, it must not contain \`, log))
+	}
+	key := strings.ToLower(log + `\` + name)
+	if other, ok := v.eventSources[key]; ok {
+		v.add(path+".name", fmt.Sprintf("duplicate event source %q in the log %s, already registered by %s", name, log, other))
+	}
+	v.eventSources[key] = path
+}
+
+// account checks the password of the service is given only for the user
+// accounts, and in a hidden property.
+func (v *validator) account(path string, s Service) {
+	account, passwordless := s.accountName()
+	switch {
+	case passwordless && s.Password != "":
+		v.add(path+".password", fmt.Sprintf("the account %s has no password", account))
+	case !passwordless && s.Password == "":
+		v.add(path+".password", fmt.Sprintf("the user account %s needs the property of its password", account))
+	case s.Password != "" && (!propertyID.MatchString(s.Password) || strings.ToUpper(s.Password) != s.Password):
+		v.add(path+".password", fmt.Sprintf("malformed property id %q, the password must be in a public property, whose id is in upper case", s.Password))
+	case s.Password != "" && v.properties[s.Password]:
+		v.add(path+".password", fmt.Sprintf("the property %s is declared hidden by the service, remove it from the properties", s.Password))
+	}
+	if strings.HasPrefix(strings.ToUpper(account), virtualAccount) && !strings.EqualFold(account[len(virtualAccount):], s.Name) {
+		v.add(path+".account", fmt.Sprintf(`the virtual account of the service is NT SERVICE\%s`, s.Name))
+	}
+	if s.Interactive == "yes" && account != "LocalSystem" {
+		v.add(path+".interactive", "only the services run by LocalSystem can be interactive")
+	}
+}
+
+var privilege = regexp.MustCompile(`^Se[A-Za-z]+Privilege$`)
+
+// serviceConfig checks the recovery and the configuration of a service.
+func (v *validator) serviceConfig(path string, s Service) {
+	if s.PreshutdownTimeout < 0 {
+		v.add(path+".preshutdown-timeout", "must not be negative")
+	}
+	for i, p := range s.Privileges {
+		if !privilege.MatchString(p) {
+			v.add(fmt.Sprintf("%s.privileges[%d]", path, i), fmt.Sprintf("invalid privilege %q, must be a privilege constant such as SeChangeNotifyPrivilege", p))
+		}
+	}
+	r := s.Recovery
+	if r == nil {
+		return
+	}
+	if r.ResetPeriod < 0 {
+		v.add(path+".recovery.reset-period", "must not be negative")
+	}
+	if r.RestartDelay < 0 {
+		v.add(path+".recovery.restart-delay", "must not be negative")
+	}
+	runs := contains([]string{r.First, r.Second, r.Subsequent}, "run-command")
+	switch {
+	case runs && r.Command == "":
+		v.add(path+".recovery.command", "must not be empty with the run-command action")
+	case !runs && r.Command != "":
+		v.add(path+".recovery.command", "is only run by the run-command action")
+	}
+}
+
+// featureTree checks the ids of the features are valid and unique.
+func (v *validator) featureTree(features []Feature, path string) {
+	for i, f := range features {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case !propertyID.MatchString(f.ID) || len(f.ID) > 38:
+			v.add(p+".id", fmt.Sprintf("malformed feature id %q, it must start with a letter or an underscore followed by up to 37 letters, digits, underscores or periods", f.ID))
+		case f.ID == DefaultFeature || v.features[f.ID]:
+			v.add(p+".id", fmt.Sprintf("duplicate feature id %q", f.ID))
+		}
+		v.features[f.ID] = true
+		v.featureTree(f.Features, p+".features")
+	}
+}
+
+// feature checks id is empty or the id of a feature.
+func (v *validator) feature(path, id string) {
+	if id != "" && !v.features[id] {
+		v.add(path, fmt.Sprintf("unknown feature %q", id))
+	}
+}
+
+// root checks id is a standard folder or a property, which a registry search
+// or the command line sets to a directory.
+func (v *validator) root(path, id string) {
+	if !contains(StandardFolders, id) && !v.properties[id] {
+		v.add(path, fmt.Sprintf("unknown root %q, must be a standard folder such as CommonFilesFolder or SystemFolder, or the id of a property", id))
+	}
+}
+
+// pathNames checks the names of the directories of a path.
+func (v *validator) pathNames(path, value string, names []string) {
+	for _, name := range names {
+		if name == "." || name == ".." || strings.ContainsAny(name, `:*?"<>|`) {
+			v.add(path, fmt.Sprintf(`invalid path %q, its names must not be . or .. nor contain : * ? " < > |`, value))
+			return
+		}
+	}
+}
+
+// source checks the source of a harvested directory is a directory.
+func (v *validator) source(path, dir string) {
+	if dir == "" {
+		return
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		v.add(path, fmt.Sprintf("directory %q not found", dir))
+	}
+}
+
+func (v *validator) registry(path, value string) {
+	p := strings.Split(value, `\`)
+	switch {
+	case len(p) < 2 || p[1] == "":
+		v.add(path, fmt.Sprintf(`invalid registry path %q, must be of the form ROOT\key`, value))
+	case !contains(RegistryRoots, p[0]):
+		v.add(path, fmt.Sprintf("invalid registry root %q, must be one of %s", p[0], strings.Join(RegistryRoots, ", ")))
+	}
+}
+
+// locate sets the position of the problems in json and jsonc documents,
+// the yaml and toml decoders do not report the positions of the values.
+func (v *validator) locate(data []byte, source string) {
+	if format, err := FormatOf(source); err != nil || data == nil || (format != FormatJSON && format != FormatJSONC) {
+		return
+	}
+	root, err := parseNodes(StripComments(data))
+	if err != nil {
+		return
+	}
+	offsets := map[string]int{}
+	var walk func(n *node, path string)
+	walk = func(n *node, path string) {
+		offsets[path] = n.start
+		for _, m := range n.members {
+			walk(m.value, strings.TrimPrefix(path+"."+m.key, "."))
+		}
+		for i, e := range n.elems {
+			walk(e, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	walk(root, "")
+	for i, p := range v.problems {
+		offset, ok := offsets[p.Path]
+		// a missing value is reported at its parent
+		for parent := p.Path; !ok && parent != ""; {
+			if j := strings.LastIndexAny(parent, ".["); j >= 0 {
+				parent = parent[:j]
+			} else {
+				parent = ""
+			}
+			offset, ok = offsets[parent]
+		}
+		line := bytes.Count(data[:offset], []byte("\n")) + 1
+		column := offset - bytes.LastIndexByte(data[:offset], '\n')
+		v.problems[i].Line, v.problems[i].Column = line, column
+	}
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+}
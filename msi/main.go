@@ -1,18 +1,25 @@
 package msi
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
-	"github.com/bmatcuk/doublestar"
-	"github.com/mh-cbon/stringexec"
+	"github.com/stirante/go-msi/builder"
 	"github.com/stirante/go-msi/manifest"
+	"github.com/stirante/go-msi/msidb"
 	"github.com/stirante/go-msi/rtf"
 	"github.com/stirante/go-msi/templates"
 	"github.com/stirante/go-msi/util"
@@ -47,7 +54,38 @@ func Main() {
 	app.Version = Version
 	app.Usage = "Easy msi pakage for Go"
 	app.UsageText = "go-msi <cmd> <options>"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "output",
+			Value: "text",
+			Usage: "Output format of the commands, text or json",
+		},
+	}
+	app.Before = func(c *cli.Context) error {
+		switch c.String("output") {
+		case "text":
+		case "json":
+			stdout = os.Stderr
+		default:
+			return usageError(fmt.Sprintf("invalid output %q, must be one of text, json", c.String("output")))
+		}
+		return nil
+	}
 	app.Commands = []cli.Command{
+		{
+			Name:  "check-json",
+			Usage: "Check the wix manifest and report all its problems",
+			Description: "The problems of wix.json and wix.jsonc files are reported with their line and column,\n" +
+				"   those of YAML and TOML files only with the path of their field, such as files[0].path,\n" +
+				"   as their decoders do not report the positions of the values.",
+			Action: checkJSON,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "path, p",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
+				},
+			},
+		},
 		{
 			Name:   "check-env",
 			Usage:  "Provide a report about your environment setup",
@@ -55,13 +93,12 @@ func Main() {
 		},
 		{
 			Name:   "add-files",
-			Usage:  "Adds files from your wix manifest",
+			Usage:  "Adds or syncs files of your wix manifest",
 			Action: addFiles,
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "path, p",
-					Value: "wix.json",
-					Usage: "Path to the wix manifest file",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
 				},
 				cli.StringFlag{
 					Name:  "dir",
@@ -77,7 +114,11 @@ func Main() {
 				},
 				cli.BoolFlag{
 					Name:  "test, t",
-					Usage: "Test mode, does not modify the wix manifest file but exits with an error instead",
+					Usage: "Test mode, does not modify the wix manifest file but exits with an error when it is not up to date, including the files --sync would remove",
+				},
+				cli.BoolFlag{
+					Name:  "sync, s",
+					Usage: "Also removes the listed files matching --includes which are missing or excluded, keeping the metadata of the others",
 				},
 			},
 		},
@@ -88,8 +129,7 @@ func Main() {
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "path, p",
-					Value: "wix.json",
-					Usage: "Path to the wix manifest file",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
 				},
 				cli.BoolFlag{
 					Name:  "force, f",
@@ -97,6 +137,32 @@ func Main() {
 				},
 			},
 		},
+		{
+			Name:   "convert",
+			Usage:  "Converts your wix manifest to another format, json, jsonc, yaml or toml",
+			Action: convert,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "path, p",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
+				},
+				cli.StringFlag{
+					Name:  "out, o",
+					Usage: "Path to the converted manifest file, its extension sets the format",
+				},
+			},
+		},
+		{
+			Name:   "schema",
+			Usage:  "Print the JSON schema of the wix manifest",
+			Action: schema,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "out, o",
+					Usage: "Path to the schema file to write, instead of printing it",
+				},
+			},
+		},
 		{
 			Name:   "generate-templates",
 			Usage:  "Generate wix templates",
@@ -104,8 +170,7 @@ func Main() {
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "path, p",
-					Value: "wix.json",
-					Usage: "Path to the wix manifest file",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
 				},
 				cli.StringFlag{
 					Name:  "src, s",
@@ -133,6 +198,10 @@ func Main() {
 					Name:  "property, pr",
 					Usage: "A property to set defined as Id=Value",
 				},
+				cli.StringFlag{
+					Name:  "compression",
+					Usage: "The compression level of the cabinet, high, low, medium, mszip or none, overrides the manifest",
+				},
 			},
 		},
 		{
@@ -180,8 +249,7 @@ func Main() {
 				},
 				cli.StringFlag{
 					Name:  "path, p",
-					Value: "wix.json",
-					Usage: "Path to the wix manifest file",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
 				},
 				cli.StringFlag{
 					Name:  "src, s",
@@ -201,6 +269,15 @@ func Main() {
 					Name:  "msi, m",
 					Usage: "Path to write resulting msi file to",
 				},
+				cli.StringFlag{
+					Name:  "toolchain, t",
+					Value: "wix",
+					Usage: "The toolchain compiling the templates, wix (candle/light) or wixl (msitools)",
+				},
+				cli.BoolFlag{
+					Name:  "wixl-drop",
+					Usage: "Let wixl drop the service recovery, permissions, folder removals and hooks it cannot build, instead of failing",
+				},
 			},
 		},
 		{
@@ -213,6 +290,11 @@ func Main() {
 					Value: tmpBuildDir,
 					Usage: "Directory path to the generated wix cmd file",
 				},
+				cli.StringFlag{
+					Name:  "toolchain, t",
+					Value: "wix",
+					Usage: "The toolchain compiling the templates, wix (candle/light) or wixl (msitools)",
+				},
 			},
 		},
 		{
@@ -226,8 +308,7 @@ func Main() {
 				},
 				cli.StringFlag{
 					Name:  "path, p",
-					Value: "wix.json",
-					Usage: "Path to the wix manifest file",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
 				},
 				cli.StringFlag{
 					Name:  "src, s",
@@ -263,10 +344,51 @@ func Main() {
 					Name:  "property, pr",
 					Usage: "A property to set defined as Id=Value",
 				},
+				cli.StringFlag{
+					Name:  "compression",
+					Usage: "The compression level of the cabinet, high, low, medium, mszip or none, overrides the manifest",
+				},
 				cli.BoolFlag{
 					Name:  "keep, k",
 					Usage: "Keep output directory containing build files (useful for debug)",
 				},
+				cli.StringFlag{
+					Name:  "backend",
+					Value: "wix",
+					Usage: "The backend building the msi file, wix (candle/light), wixl (msitools, no installer UI) or native (pure Go, no installer UI)",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Usage: "Maximum duration of the toolchain commands, for example 5m (no limit by default)",
+				},
+				cli.BoolFlag{
+					Name:  "wixl-drop",
+					Usage: "Let wixl drop the service recovery, permissions, folder removals and hooks it cannot build, instead of failing",
+				},
+			},
+		},
+		{
+			Name:   "inspect",
+			Usage:  "Print the tables and summary information of an msi file",
+			Action: inspect,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "msi, m",
+					Usage: "Path to the msi file to inspect",
+				},
+				cli.StringFlag{
+					Name:  "table, t",
+					Usage: "Name of the table to print, all tables are listed if omitted",
+				},
+				cli.StringFlag{
+					Name:  "format, f",
+					Value: "text",
+					Usage: "Output format, text, csv or json",
+				},
+				cli.BoolFlag{
+					Name:  "summary",
+					Usage: "Print the summary information stream",
+				},
 			},
 		},
 		{
@@ -276,8 +398,7 @@ func Main() {
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "path, p",
-					Value: "wix.json",
-					Usage: "Path to the wix manifest file",
+					Usage: "Path to the wix manifest file, wix.json, wix.jsonc, wix.yaml, wix.yml or wix.toml when empty",
 				},
 				cli.StringFlag{
 					Name:  "src, s",
@@ -301,6 +422,10 @@ func Main() {
 					Name:  "changelog-cmd, c",
 					Usage: "A command to generate the content of the changlog in the package",
 				},
+				cli.StringFlag{
+					Name:  "compression",
+					Usage: "The compression level of the cabinet, high, low, medium, mszip or none, overrides the manifest",
+				},
 				cli.BoolFlag{
 					Name:  "keep, k",
 					Usage: "Keep output directory containing build files (useful for debug)",
@@ -309,8 +434,15 @@ func Main() {
 		},
 	}
 
+	for i, command := range app.Commands {
+		app.Commands[i].Action = withOutput(command.Action.(func(*cli.Context) error))
+	}
+
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		// the json output already describes the error
+		if msg := err.Error(); msg != "" {
+			fmt.Fprintln(os.Stderr, msg)
+		}
 		if e, ok := err.(*cli.ExitError); ok {
 			os.Exit(e.ExitCode())
 		}
@@ -320,51 +452,86 @@ func Main() {
 
 var verReg = regexp.MustCompile(`\s[0-9]+[.][0-9]+[.][0-9]+`)
 
+// envCheck is the result of an environment check.
+type envCheck struct {
+	Tool     string `json:"tool"`
+	Status   string `json:"status"` // ok, missing, unknown or outdated
+	Version  string `json:"version,omitempty"`
+	Required string `json:"required,omitempty"`
+	Message  string `json:"message"`
+}
+
 func checkEnv(c *cli.Context) error {
+	var checks []envCheck
 	for _, b := range []string{"light", "candle"} {
-		if out, err := util.Exec(b, "-h"); out == "" {
-			fmt.Printf("!!	%v not found: %q\n", b, err)
-		} else {
-			match := verReg.FindAllString(out, -1)
-			if len(match) < 1 {
-				fmt.Printf("??	%v probably not found\n", b)
-			} else {
-				version := strings.TrimSpace(match[0])
-				ver, err := semver.NewVersion(version)
-				if err != nil {
-					fmt.Printf("??	%v found but its version is not parsable %v\n", b, version)
-				} else {
-					min := "3.10.0"
-					if !ver.GreaterThan(semver.MustParse(min)) {
-						fmt.Printf("!!	%v found %v but %v is required\n", b, version, min)
-					} else {
-						fmt.Printf("ok	%v found %v\n", b, version)
-					}
-				}
-			}
+		checks = append(checks, checkTool(b, b, "", "3.10.0", "%v", "-h"))
+	}
+	checks = append(checks, checkTool("chocolatey", "choco", " ", "0.10.0", ">%v", "-v"))
+	for _, check := range checks {
+		mark := map[string]string{"ok": "ok", "unknown": "??"}[check.Status]
+		if mark == "" {
+			mark = "!!"
 		}
+		fmt.Fprintf(stdout, "%s	%s\n", mark, check.Message)
 	}
-	if out, err := util.Exec("choco", "-v"); out == "" {
-		fmt.Printf("!!	%v not found: %q\n", "chocolatey", err)
+	setResult(c, checks)
+	return nil
+}
+
+// checkTool runs bin with args to check its version is greater than min.
+func checkTool(name, bin, prefix, min, required string, args ...string) envCheck {
+	check := envCheck{Tool: name, Required: min}
+	out, err := util.Exec(bin, args...)
+	if out == "" {
+		check.Status, check.Message = "missing", fmt.Sprintf("%v not found: %q", name, err)
+		return check
+	}
+	match := verReg.FindAllString(prefix+out, -1)
+	if len(match) < 1 {
+		check.Status, check.Message = "unknown", fmt.Sprintf("%v probably not found", name)
+		return check
+	}
+	check.Version = strings.TrimSpace(match[0])
+	ver, err := semver.NewVersion(check.Version)
+	if err != nil {
+		check.Status, check.Message = "unknown", fmt.Sprintf("%v found but its version is not parsable %v", name, check.Version)
+	} else if !ver.GreaterThan(semver.MustParse(min)) {
+		check.Status, check.Message = "outdated", fmt.Sprintf("%v found %v but "+required+" is required", name, check.Version, min)
 	} else {
-		match := verReg.FindAllString(" "+out, -1)
-		if len(match) < 1 {
-			fmt.Printf("??	%v probably not found\n", "chocolatey")
+		check.Status, check.Message = "ok", fmt.Sprintf("%v found %v", name, check.Version)
+	}
+	return check
+}
+
+func checkJSON(c *cli.Context) error {
+	path := c.String("path")
+
+	wixFile := manifest.WixManifest{}
+	if err := wixFile.Load(path); err != nil {
+		return exitError(err)
+	}
+	problems := wixFile.Validate()
+	for _, p := range problems {
+		if p.Line > 0 {
+			fmt.Fprintf(stdout, "%s:%s\n", wixFile.Source(), p)
 		} else {
-			version := strings.TrimSpace(match[0])
-			ver, err := semver.NewVersion(version)
-			if err != nil {
-				fmt.Printf("??	%v found but its version is not parsable %v\n", "chocolatey", version)
-			} else {
-				min := "0.10.0"
-				if !ver.GreaterThan(semver.MustParse(min)) {
-					fmt.Printf("!!	%v found %v but >%v is required\n", "chocolatey", version, min)
-				} else {
-					fmt.Printf("ok	%v found %v\n", "chocolatey", version)
-				}
-			}
+			fmt.Fprintf(stdout, "%s: %s\n", wixFile.Source(), p)
+		}
+	}
+	if len(problems) > 0 {
+		found := fmt.Sprintf("%d problems", len(problems))
+		if len(problems) == 1 {
+			found = "1 problem"
+		}
+		return &commandError{
+			code:     codeInvalid,
+			err:      fmt.Errorf("%s found in %s", found, wixFile.Source()),
+			problems: problems,
 		}
 	}
+	fmt.Fprintf(stdout, "%s is valid\n", wixFile.Source())
+	setResult(c, map[string]interface{}{"file": wixFile.Source(), "problems": []manifest.Problem{}})
+
 	return nil
 }
 
@@ -374,107 +541,98 @@ func addFiles(c *cli.Context) error {
 	includes := c.StringSlice("includes")
 	excludes := c.StringSlice("excludes")
 	test := c.Bool("test")
+	sync := c.Bool("sync")
 
 	if dir == "" {
-		return cli.NewExitError(fmt.Errorf("--dir argument is required"), 1)
+		return usageError("--dir argument is required")
 	}
 	if len(includes) == 0 {
-		return cli.NewExitError(fmt.Errorf("--includes argument is required"), 1)
+		return usageError("--includes argument is required")
 	}
 	wixFile := manifest.WixManifest{}
 	err := wixFile.Load(path)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
+	list := &fileList{}
 	out := make(map[string]bool)
-	err = glob(dir, excludes, func(match string) {
+	err = manifest.Glob(dir, excludes, func(match string) {
 		out[match] = true
 	}, false)
 	if err != nil {
-		return cli.NewExitError(err, 1)
+		return exitError(err)
 	}
-	err = glob(dir, includes, func(match string) {
+	// the listed files matched by the includes are compared with the
+	// files found, a pattern matching nothing removes its files.
+	diff := sync || test
+	matched := make(map[string]bool)
+	err = manifest.Glob(dir, includes, func(match string) {
 		file := manifest.File{Path: filepath.ToSlash(filepath.Join(dir, match))}
 		if out[match] {
-			fmt.Println("    excluding", file.Path)
+			fmt.Fprintln(stdout, "    excluding", file.Path)
+			list.Excluded = append(list.Excluded, file.Path)
 			return
 		}
-		path := strings.Split(match, "/")
-		wixFile.Directory = addFile(wixFile.Directory, file, path)
-	}, true)
+		matched[file.Path] = true
+		if wixFile.Directory.AddFile(file, match) {
+			fmt.Fprintln(stdout, "    adding", file.Path)
+			list.Added = append(list.Added, file.Path)
+		} else {
+			list.Unchanged = append(list.Unchanged, file.Path)
+		}
+	}, !diff)
 	if err != nil {
-		return cli.NewExitError(err, 1)
+		return exitError(err)
+	}
+	if diff {
+		removed := wixFile.Directory.RemoveFiles(func(f manifest.File) bool {
+			rel, ok := relPath(dir, f.Path)
+			return ok && manifest.Match(includes, rel) && !matched[filepath.ToSlash(filepath.Clean(f.Path))]
+		})
+		for _, f := range removed {
+			fmt.Fprintln(stdout, "    removing", f.Path)
+			list.Removed = append(list.Removed, f.Path)
+		}
 	}
+	fmt.Fprintf(stdout, "%d added, %d removed, %d unchanged\n", len(list.Added), len(list.Removed), len(list.Unchanged))
 
+	list.UpToDate = len(list.Added) == 0 && len(list.Removed) == 0
+	setResult(c, list)
 	if test {
-		return fmt.Errorf("file list not up to date")
+		if !list.UpToDate {
+			return exitError(fmt.Errorf("file list not up to date"))
+		}
+		fmt.Fprintln(stdout, "The file list is up to date")
+		return nil
 	}
 	err = wixFile.Write(path)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
-	fmt.Println("The file is saved on disk")
+	list.Saved = true
+	fmt.Fprintln(stdout, "The file is saved on disk")
 	return nil
 }
 
-func addFile(dir manifest.Directory, file manifest.File, path []string) manifest.Directory {
-	if len(path) == 1 {
-		for _, f := range dir.Files {
-			if f.Path == file.Path {
-				fmt.Println("    skipping", file.Path, "already listed")
-				return dir
-			}
-		}
-		fmt.Println("    adding", file.Path)
-		dir.Files = append(dir.Files, file)
-		return dir
-	}
-	for i, d := range dir.Directories {
-		if d.Name == path[0] {
-			dir.Directories[i] = addFile(d, file, path[1:])
-			return dir
-		}
-	}
-	d := addFile(manifest.Directory{Name: path[0]}, file, path[1:])
-	dir.Directories = append(dir.Directories, d)
-	return dir
+// fileList reports the files processed by add-files.
+type fileList struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+	Excluded  []string `json:"excluded"`
+	UpToDate  bool     `json:"up-to-date"`
+	Saved     bool     `json:"saved"`
 }
 
-func glob(dir string, patterns []string, f func(match string), fail bool) error {
-	for _, pattern := range patterns {
-		var matches []string
-		for _, file := range strings.Split(pattern, ",") {
-			match, err := doublestar.Glob(filepath.Join(dir, file))
-			if err != nil {
-				return err
-			}
-			if fail && match == nil {
-				return fmt.Errorf("files %q do not exist", pattern)
-			}
-			matches = append(matches, match...)
-		}
-		for _, match := range matches {
-			info, err := os.Stat(match)
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				continue
-			}
-			abs, err := filepath.Abs(dir)
-			if err != nil {
-				return cli.NewExitError(err.Error(), 1)
-			}
-			fmt.Println(abs)
-			rel, err := filepath.Rel(abs, match)
-			if err != nil {
-				return err
-			}
-			f(filepath.ToSlash(rel))
-		}
+// relPath returns the slash separated path of the file p relative to the
+// directory dir, and false when p is not in dir.
+func relPath(dir, p string) (string, bool) {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(filepath.FromSlash(p)))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
-	return nil
+	return filepath.ToSlash(rel), true
 }
 
 func setGUID(c *cli.Context) error {
@@ -484,97 +642,98 @@ func setGUID(c *cli.Context) error {
 	wixFile := manifest.WixManifest{}
 	err := wixFile.Load(path)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
 	updated, err := wixFile.SetGuids(force)
 	if err != nil {
-		return err
+		return exitError(err)
 	}
 	if updated {
-		fmt.Println("The manifest was updated")
+		fmt.Fprintln(stdout, "The manifest was updated")
 	} else {
-		fmt.Println("The manifest was not updated")
+		fmt.Fprintln(stdout, "The manifest was not updated")
 	}
 
 	err = wixFile.Write(path)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
-	fmt.Println("The file is saved on disk")
+	fmt.Fprintln(stdout, "The file is saved on disk")
+	setResult(c, map[string]interface{}{"updated": updated, "upgrade-code": wixFile.UpgradeCode})
 
 	return nil
 }
 
-func generateTemplates(c *cli.Context) error {
+func convert(c *cli.Context) error {
 	path := c.String("path")
-	src := c.String("src")
 	out := c.String("out")
-	compression := c.String("compression")
-	version := c.String("version")
-	display := c.String("display")
-	license := c.String("license")
-	properties := c.StringSlice("property")
 
-	wixFile := manifest.WixManifest{}
-	err := wixFile.Load(path)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	if out == "" {
+		return usageError("--out argument is required")
 	}
-
-	if wixFile.NeedGUID() {
-		fmt.Println("The manifest needs Guid")
-		fmt.Println("To update your file automatically run:")
-		fmt.Println("     go-msi set-guid")
-		return cli.NewExitError("Cannot proceed, manifest file is incomplete", 1)
+	if path == "" {
+		var err error
+		if path, err = manifest.FindFile("."); err != nil {
+			return exitError(err)
+		}
 	}
-
-	wixFile.Compression = compression
-	wixFile.Version.User = version
-	wixFile.Version.Display = display
-
-	if c.IsSet("license") {
-		wixFile.License = license
+	if _, err := manifest.FormatOf(out); err != nil {
+		return usageError(err.Error())
 	}
-
-	if err := addProperties(&wixFile, properties); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	if err := manifest.ConvertFile(path, out); err != nil {
+		return exitError(err)
 	}
+	fmt.Fprintf(stdout, "%s converted to %s\n", path, out)
+	setResult(c, map[string]string{"file": out})
 
-	if err := wixFile.Normalize(); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
+	return nil
+}
 
-	if err := wixFile.RewriteFilePaths(out); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
+func schema(c *cli.Context) error {
+	out := c.String("out")
 
-	tpls, err := templates.Find(src, "*.wxs")
+	schema, err := manifest.Schema()
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
-	if len(tpls) == 0 {
-		return cli.NewExitError("No templates *.wxs found in this directory", 1)
+	if out != "" {
+		if err := ioutil.WriteFile(out, append(schema, '\n'), 0644); err != nil {
+			return exitError(err)
+		}
+		fmt.Fprintf(stdout, "The schema is saved in %s\n", out)
+		setResult(c, map[string]string{"file": out})
+		return nil
 	}
-
-	err = os.MkdirAll(out, 0744)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	if outputJSON(c) {
+		setResult(c, json.RawMessage(schema))
+		return nil
 	}
+	fmt.Fprintln(stdout, string(schema))
 
-	for _, tpl := range tpls {
-		dst := filepath.Join(out, filepath.Base(tpl))
-		err = templates.GenerateTemplate(&wixFile, tpl, dst)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
-		}
+	return nil
+}
+
+func generateTemplates(c *cli.Context) error {
+	generated, err := builder.GenerateTemplates(builder.Options{
+		Manifest:    c.String("path"),
+		Templates:   c.String("src"),
+		Out:         c.String("out"),
+		Compression: c.String("compression"),
+		Version:     c.String("version"),
+		Display:     c.String("display"),
+		License:     c.String("license"),
+		Properties:  c.StringSlice("property"),
+		Logger:      builder.NewLogger(stdout),
+	})
+	if err != nil {
+		return exitError(err)
 	}
 
-	fmt.Printf("Generated %d templates\n", len(tpls))
-	for _, tpl := range tpls {
-		dst := filepath.Join(out, filepath.Base(tpl))
-		fmt.Printf("- %s\n", dst)
+	for _, dst := range generated {
+		fmt.Fprintf(stdout, "- %s\n", dst)
 	}
+	setResult(c, map[string]interface{}{"templates": generated})
 
 	return nil
 }
@@ -584,19 +743,20 @@ func toWindows1252(c *cli.Context) error {
 	out := c.String("out")
 
 	if src == "" {
-		return cli.NewExitError("--src argument is required", 1)
+		return usageError("--src argument is required")
 	}
 	if out == "" {
-		return cli.NewExitError("--out argument is required", 1)
+		return usageError("--out argument is required")
 	}
 	if _, err := os.Stat(src); os.IsNotExist(err) {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 	os.MkdirAll(filepath.Dir(out), 0744)
 	err := rtf.WriteAsWindows1252(src, out)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
+	setResult(c, map[string]string{"file": out})
 	return nil
 }
 
@@ -606,21 +766,22 @@ func toRtf(c *cli.Context) error {
 	reencode := c.Bool("reencode")
 
 	if src == "" {
-		return cli.NewExitError("--src argument is required", 1)
+		return usageError("--src argument is required")
 	}
 	if out == "" {
-		return cli.NewExitError("--out argument is required", 1)
+		return usageError("--out argument is required")
 	}
 	if _, err := os.Stat(src); os.IsNotExist(err) {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
 	os.MkdirAll(filepath.Dir(out), 0744)
 
 	err := rtf.WriteAsRtf(src, out, reencode)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
+	setResult(c, map[string]string{"file": out})
 
 	return nil
 }
@@ -634,15 +795,23 @@ func generateWixCommands(c *cli.Context) error {
 	bin := c.String("bin")
 
 	if msi == "" {
-		return cli.NewExitError("--msi parameter must be set", 1)
+		return usageError("--msi parameter must be set")
+	}
+	toolchain, err := wix.Find(c.String("toolchain"))
+	if err != nil {
+		return usageError(err.Error())
+	}
+	if w, ok := toolchain.(wix.Wixl); ok {
+		w.Drop = c.Bool("wixl-drop")
+		toolchain = w
 	}
 
 	templates, err := templates.Find(src, "*.wxs")
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 	if len(templates) == 0 {
-		return cli.NewExitError("No templates *.wxs found in this directory", 1)
+		return exitError(fmt.Errorf("No templates *.wxs found in this directory"))
 	}
 
 	builtTemplates := make([]string, len(templates))
@@ -653,50 +822,47 @@ func generateWixCommands(c *cli.Context) error {
 	wixFile := manifest.WixManifest{}
 	err = wixFile.Load(path)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
 	if wixFile.NeedGUID() {
-		fmt.Println("The manifest needs Guid")
-		fmt.Println("To update your file automatically run:")
-		fmt.Println("     go-msi set-guid")
-		return cli.NewExitError("Cannot proceed, manifest file is incomplete", 1)
+		fmt.Fprintln(stdout, "The manifest needs Guid")
+		fmt.Fprintln(stdout, "To update your file automatically run:")
+		fmt.Fprintln(stdout, "     go-msi set-guid")
+		return exitError(fmt.Errorf("Cannot proceed, manifest file is incomplete"))
 	}
 
 	if err := wixFile.Normalize(); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
 	if err := wixFile.RewriteFilePaths(out); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
 	msi, err = filepath.Abs(msi)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 	abs, err := filepath.Abs(out)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 	msi, err = filepath.Rel(abs, msi)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
 
 	if bin != "" {
 		if bin, err = filepath.Abs(bin); err != nil {
-			return cli.NewExitError(err.Error(), 1)
+			return exitError(err)
 		}
 	}
 
-	cmdStr := wix.GenerateCmd(&wixFile, builtTemplates, msi, arch, bin)
-
-	targetFile := filepath.Join(out, "build.bat")
-	err = ioutil.WriteFile(targetFile, []byte(cmdStr), 0644)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	if err := writeBuildScript(toolchain, builtTemplates, msi, arch, bin, out); err != nil {
+		return exitError(err)
 	}
+	setResult(c, map[string]string{"script": filepath.Join(out, toolchain.Script())})
 
 	return nil
 }
@@ -704,282 +870,317 @@ func generateWixCommands(c *cli.Context) error {
 func runWixCommands(c *cli.Context) error {
 	out := c.String("out")
 
-	oCmd := exec.Command("cmd.exe", "/C", "build.bat")
-	oCmd.Dir = out
-	oCmd.Stdout = os.Stdout
-	oCmd.Stderr = os.Stderr
-	err := oCmd.Run()
+	toolchain, err := wix.Find(c.String("toolchain"))
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return usageError(err.Error())
+	}
+
+	if err := runBuildScript(toolchain, out); err != nil {
+		return exitError(err)
 	}
+	setResult(c, map[string]string{"script": filepath.Join(out, toolchain.Script())})
 
 	return nil
 }
 
-func quickMake(c *cli.Context) error {
-	path := c.String("path")
-	src := c.String("src")
-	out := c.String("out")
-	compression := c.String("compression")
-	version := c.String("version")
-	display := c.String("display")
-	license := c.String("license")
-	properties := c.StringSlice("property")
-	msi := c.String("msi")
-	arch := c.String("arch")
-	keep := c.Bool("keep")
-	bin := c.String("bin")
-
-	if msi == "" {
-		return cli.NewExitError("--msi parameter must be set", 1)
+// prepareTemplates adapts the templates to the toolchain,
+// it returns the templates to compile.
+func prepareTemplates(toolchain wix.Toolchain, templates []string) ([]string, error) {
+	sources, notes, err := toolchain.Prepare(templates)
+	if err != nil {
+		return nil, err
 	}
-
-	wixFile := manifest.WixManifest{}
-	if err := wixFile.Load(path); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	for _, note := range notes {
+		fmt.Fprintf(stdout, "%s: %s\n", toolchain.Name(), note)
 	}
+	return sources, nil
+}
 
-	if _, err := wixFile.SetGuids(false); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+// writeBuildScript writes the script compiling the templates into out.
+func writeBuildScript(toolchain wix.Toolchain, templates []string, msi, arch, bin, out string) error {
+	sources, err := prepareTemplates(toolchain, templates)
+	if err != nil {
+		return err
 	}
 
-	if err := os.RemoveAll(out); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
-	if err := os.MkdirAll(out, 0744); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
+	cmdStr := toolchain.GenerateCmd(sources, msi, arch, bin)
 
-	wixFile.Compression = compression
-	wixFile.Version.User = version
-	wixFile.Version.Display = display
+	targetFile := filepath.Join(out, toolchain.Script())
+	return ioutil.WriteFile(targetFile, []byte(cmdStr), 0644)
+}
 
-	if c.IsSet("license") {
-		wixFile.License = license
-	}
-	if wixFile.License != "" {
-		isRtf, err := rtf.IsRtf(wixFile.License)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
-		}
-		if !isRtf {
-			fmt.Println("Converting license to RTF")
-			target := filepath.Join(out, filepath.Base(wixFile.License)+".rtf")
-			if err := rtf.WriteAsRtf(wixFile.License, target, true); err != nil {
-				return cli.NewExitError(err.Error(), 1)
-			}
-			wixFile.License = target
+func runBuildScript(toolchain wix.Toolchain, out string) error {
+	args := toolchain.Command()
+	oCmd := exec.Command(args[0], args[1:]...)
+	oCmd.Dir = out
+	oCmd.Stdout = stdout
+	oCmd.Stderr = os.Stderr
+	return oCmd.Run()
+}
+
+func quickMake(c *cli.Context) error {
+	timeout := c.Duration("timeout")
+
+	if c.String("msi") == "" {
+		return usageError("--msi parameter must be set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
+
+	result, err := builder.Build(ctx, builder.Options{
+		Manifest:    c.String("path"),
+		Templates:   c.String("src"),
+		Out:         c.String("out"),
+		MSI:         c.String("msi"),
+		Arch:        c.String("arch"),
+		Version:     c.String("version"),
+		Display:     c.String("display"),
+		License:     c.String("license"),
+		Compression: c.String("compression"),
+		Properties:  c.StringSlice("property"),
+		Backend:     c.String("backend"),
+		Bin:         c.String("bin"),
+		Keep:        c.Bool("keep"),
+		WixlDrop:    c.Bool("wixl-drop"),
+		Stdout:      stdout,
+		Stderr:      os.Stderr,
+		Logger:      builder.NewLogger(stdout),
+	})
+	if err != nil {
+		return exitError(err)
 	}
 
-	if err := addProperties(&wixFile, properties); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
+	fmt.Fprintln(stdout, "All Done!!")
+	setResult(c, result)
 
-	if err := wixFile.Normalize(); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
-
-	if err := wixFile.RewriteFilePaths(out); err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
+	return nil
+}
 
-	tpls, err := templates.Find(src, "*.wxs")
+func chocoMake(c *cli.Context) error {
+	result, err := builder.Choco(context.Background(), builder.ChocoOptions{
+		Options: builder.Options{
+			Manifest:    c.String("path"),
+			Templates:   c.String("src"),
+			Out:         c.String("out"),
+			Version:     c.String("version"),
+			Compression: c.String("compression"),
+			Keep:        c.Bool("keep"),
+			Stdout:      stdout,
+			Stderr:      os.Stderr,
+			Logger:      builder.NewLogger(stdout),
+		},
+		Input:        c.String("input"),
+		ChangelogCmd: c.String("changelog-cmd"),
+	})
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
-	if len(tpls) == 0 {
-		return cli.NewExitError("No templates *.wxs found in this directory", 1)
-	}
-
-	builtTemplates := make([]string, len(tpls))
-	for i, tpl := range tpls {
-		dst := filepath.Join(out, filepath.Base(tpl))
-		err = templates.GenerateTemplate(&wixFile, tpl, dst)
-		builtTemplates[i] = dst
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
-		}
+		return exitError(err)
 	}
 
-	msi, err = filepath.Abs(msi)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
-	abs, err := filepath.Abs(out)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
-	msi, err = filepath.Rel(abs, msi)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
+	fmt.Fprintln(stdout, "All Done!!")
+	setResult(c, result)
 
-	if bin != "" {
-		if bin, err = filepath.Abs(bin); err != nil {
-			return cli.NewExitError(err.Error(), 1)
-		}
-	}
+	return nil
+}
 
-	cmdStr := wix.GenerateCmd(&wixFile, builtTemplates, msi, arch, bin)
+func inspect(c *cli.Context) error {
+	path := c.String("msi")
+	table := c.String("table")
+	format := c.String("format")
+	summary := c.Bool("summary")
 
-	targetFile := filepath.Join(out, "build.bat")
-	err = ioutil.WriteFile(targetFile, []byte(cmdStr), 0644)
+	if path == "" {
+		return usageError("--msi parameter must be set")
+	}
+	if format != "text" && format != "csv" && format != "json" {
+		return usageError(fmt.Sprintf("invalid format %q, must be one of text, csv, json", format))
+	}
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(err)
 	}
-
-	oCmd := exec.Command("cmd.exe", "/C", "build.bat")
-	oCmd.Dir = out
-	oCmd.Stdout = os.Stdout
-	oCmd.Stderr = os.Stderr
-	err = oCmd.Run()
+	db, err := msidb.Read(data)
 	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+		return exitError(fmt.Errorf("Failed to read %s: %v", path, err))
 	}
 
-	if keep == false {
-		err = os.RemoveAll(out)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
+	// the json format is printed as the command result with --output json
+	encode := json.NewEncoder(os.Stdout).Encode
+	if outputJSON(c) {
+		format = "json"
+		encode = func(v interface{}) error {
+			setResult(c, v)
+			return nil
 		}
-	} else {
-		fmt.Printf("Build files are available in %s\n", out)
 	}
 
-	fmt.Println("All Done!!")
-
-	return nil
-}
-
-func addProperties(wixFile *manifest.WixManifest, properties []string) error {
-	for _, prop := range properties {
-		s := strings.SplitN(prop, "=", 2)
-		if len(s) < 2 {
-			return fmt.Errorf("property definition must be of the form Id=Value")
+	switch {
+	case summary:
+		err = printSummary(os.Stdout, db.Summary, format, encode)
+	case table != "":
+		var t *msidb.Table
+		for _, tbl := range db.Tables {
+			if tbl.Name == table {
+				t = tbl
+			}
 		}
-		v := manifest.Value(s[1])
-		wixFile.Properties = append(wixFile.Properties,
-			manifest.Property{
-				ID:    s[0],
-				Value: &v,
-			},
-		)
+		if t == nil {
+			return &commandError{code: codeNotFound, err: fmt.Errorf("No table %q in %s", table, path)}
+		}
+		err = printTable(os.Stdout, t, format, encode)
+	default:
+		err = printTables(os.Stdout, db, format, encode)
+	}
+	if err != nil {
+		return exitError(err)
 	}
 	return nil
 }
 
-func chocoMake(c *cli.Context) error {
-	path := c.String("path")
-	src := c.String("src")
-	out := c.String("out")
-	input := c.String("input")
-	compression := c.String("compression")
-	version := c.String("version")
-	changelogCmd := c.String("changelog-cmd")
-	keep := c.Bool("keep")
-
-	wixFile := manifest.WixManifest{}
-	if err := wixFile.Load(path); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+func printTables(w io.Writer, db *msidb.Database, format string, encode func(interface{}) error) error {
+	list := &msidb.Table{
+		Name:    "Tables",
+		Columns: []msidb.Column{{Name: "Name", Type: "s64", Key: true}, {Name: "Rows", Type: "i4"}},
 	}
-
-	if err := os.RemoveAll(out); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	for _, t := range db.Tables {
+		list.Add(t.Name, len(t.Rows))
 	}
-	if err := os.MkdirAll(out, 0744); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	streams := &msidb.Table{
+		Name:    "Streams",
+		Columns: []msidb.Column{{Name: "Name", Type: "s64", Key: true}, {Name: "Size", Type: "i4"}},
 	}
-
-	wixFile.Compression = compression
-	wixFile.Version.User = version
-
-	if err := wixFile.Normalize(); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	for name, data := range db.Streams {
+		streams.Add(name, len(data))
 	}
-
-	tpls, err := templates.Find(src, "*")
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	sort.Slice(streams.Rows, func(i, j int) bool {
+		return streams.Rows[i][0].(string) < streams.Rows[j][0].(string)
+	})
+	if format == "json" {
+		return encode(map[string]interface{}{
+			"tables":  tableObjects(list),
+			"streams": tableObjects(streams),
+		})
 	}
-	if len(tpls) == 0 {
-		return cli.NewExitError("No templates found in this directory", 1)
+	if err := printTable(w, list, format, encode); err != nil {
+		return err
 	}
+	fmt.Fprintln(w)
+	return printTable(w, streams, format, encode)
+}
 
-	out, err = filepath.Abs(out)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
-	}
-	wixFile.Choco.BuildDir = out
-	wixFile.Choco.MsiFile = filepath.Base(input)
-	wixFile.Choco.MsiSum, err = util.ComputeSha256(input)
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+func printSummary(w io.Writer, s msidb.SummaryInfo, format string, encode func(interface{}) error) error {
+	t := &msidb.Table{
+		Name:    "SummaryInformation",
+		Columns: []msidb.Column{{Name: "Property", Type: "s32", Key: true}, {Name: "Value", Type: "S0"}},
 	}
-
-	if changelogCmd != "" {
-		windows, err := stringexec.Command(changelogCmd)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
-		}
-		windows.Stderr = os.Stderr
-		out, err := windows.Output()
-		if err != nil {
-			return cli.NewExitError(fmt.Sprintf("Failed to execute command to generate the changelog:%q\n%v", changelogCmd, err.Error()), 1)
+	date := func(d time.Time) string {
+		if d.IsZero() {
+			return ""
 		}
-		sout := string(out)
-		souts := strings.Split(sout, "\n")
-		if len(souts) > 2 {
-			souts = souts[2:] // why ? command line artifacts ? todo: put an explanation here.
+		return d.Format(time.RFC3339)
+	}
+	t.Add("Title", s.Title)
+	t.Add("Subject", s.Subject)
+	t.Add("Author", s.Author)
+	t.Add("Keywords", s.Keywords)
+	t.Add("Comments", s.Comments)
+	t.Add("Template", s.Template)
+	t.Add("LastAuthor", s.LastAuthor)
+	t.Add("Revision", s.Revision)
+	t.Add("CreateTime", date(s.CreateTime))
+	t.Add("SaveTime", date(s.SaveTime))
+	t.Add("PageCount", fmt.Sprint(s.PageCount))
+	t.Add("WordCount", fmt.Sprint(s.WordCount))
+	t.Add("AppName", s.AppName)
+	t.Add("Security", fmt.Sprint(s.Security))
+	if format == "json" {
+		obj := make(map[string]string)
+		for _, row := range t.Rows {
+			obj[row[0].(string)] = row[1].(string)
 		}
-		sout = strings.Join(souts, "\n")
-
-		wixFile.Choco.ChangeLog = sout
+		return encode(obj)
 	}
+	return printTable(w, t, format, encode)
+}
 
-	if err := util.CopyFile(filepath.Join(wixFile.Choco.BuildDir, wixFile.Choco.MsiFile), input); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+// printTable prints t in the idt format used by msidb.exe when the format is text.
+func printTable(w io.Writer, t *msidb.Table, format string, encode func(interface{}) error) error {
+	if format == "json" {
+		return encode(tableObjects(t))
+	}
+	var names, types, keys []string
+	keys = append(keys, t.Name)
+	for _, c := range t.Columns {
+		names = append(names, c.Name)
+		types = append(types, c.Type)
+		if c.Key {
+			keys = append(keys, c.Name)
+		}
 	}
-
-	for _, tpl := range tpls {
-		dst := filepath.Join(out, filepath.Base(tpl))
-		err := templates.GenerateTemplate(&wixFile, tpl, dst)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
+	rows := [][]string{names}
+	if format == "text" {
+		rows = append(rows, types, keys)
+	}
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cellString(v)
 		}
+		rows = append(rows, cells)
 	}
-
-	bin, err := exec.LookPath("choco")
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	if format == "csv" {
+		out := csv.NewWriter(w)
+		out.WriteAll(rows)
+		return out.Error()
 	}
-	oCmd := exec.Command(bin, "pack")
-	oCmd.Dir = out
-	oCmd.Stdout = os.Stdout
-	oCmd.Stderr = os.Stderr
-	err = oCmd.Run()
-	if err != nil {
-		return cli.NewExitError(err.Error(), 1)
+	escape := strings.NewReplacer("\t", "\x10", "\r", "\x11", "\n", "\x19")
+	for _, row := range rows {
+		for i := range row {
+			row[i] = escape.Replace(row[i])
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	SrcNupkg := fmt.Sprintf("%s\\%s.%s.nupkg", out, wixFile.Choco.ID, wixFile.Version.MSI)
-	DstNupkg := fmt.Sprintf("%s.%s.nupkg", wixFile.Choco.ID, wixFile.Version.User)
-
-	if err = util.CopyFile(DstNupkg, SrcNupkg); err != nil {
-		return cli.NewExitError(err.Error(), 1)
+func cellString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return fmt.Sprintf("[%d bytes]", len(v))
 	}
+	return fmt.Sprint(v)
+}
 
-	if keep == false {
-		err = os.RemoveAll(out)
-		if err != nil {
-			return cli.NewExitError(err.Error(), 1)
+func tableObjects(t *msidb.Table) []map[string]interface{} {
+	objects := make([]map[string]interface{}, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		obj := make(map[string]interface{})
+		for i, c := range t.Columns {
+			if b, ok := row[i].([]byte); ok {
+				obj[c.Name] = fmt.Sprintf("[%d bytes]", len(b))
+			} else {
+				obj[c.Name] = row[i]
+			}
 		}
-	} else {
-		fmt.Printf("Build files are available in %s\n", out)
+		objects = append(objects, obj)
 	}
-
-	fmt.Printf("Package copied to %s\n", DstNupkg)
-	fmt.Println("All Done!!")
-
-	return nil
+	return objects
 }
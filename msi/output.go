@@ -0,0 +1,128 @@
+package msi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/stirante/go-msi/builder"
+	"github.com/stirante/go-msi/manifest"
+	"github.com/stirante/go-msi/wix"
+	"github.com/urfave/cli"
+)
+
+// stdout receives the human readable output of the commands,
+// it is redirected to stderr when the output is json.
+var stdout io.Writer = os.Stdout
+
+// document is printed by the commands when the output is json.
+type document struct {
+	Command string      `json:"command"`
+	Success bool        `json:"success"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *docError   `json:"error,omitempty"`
+}
+
+type docError struct {
+	Code        string               `json:"code"`
+	Message     string               `json:"message"`
+	ExitCode    *int                 `json:"exit-code,omitempty"`
+	Diagnostics []builder.Diagnostic `json:"diagnostics,omitempty"`
+	Problems    []manifest.Problem   `json:"problems,omitempty"`
+}
+
+// Error codes of the json output.
+const (
+	codeError     = "error"
+	codeUsage     = "usage"
+	codeNotFound  = "not-found"
+	codeToolchain = "toolchain"
+	codeTimeout   = "timeout"
+	codeCanceled  = "canceled"
+	codeInvalid   = "invalid"
+)
+
+// commandError is an error carrying its json error code.
+type commandError struct {
+	code     string
+	err      error
+	problems []manifest.Problem // of an invalid manifest
+}
+
+func (e *commandError) Error() string { return e.err.Error() }
+
+// ExitCode implements cli.ExitCoder.
+func (e *commandError) ExitCode() int { return 1 }
+
+// usageError reports invalid command arguments.
+func usageError(message string) error {
+	return &commandError{code: codeUsage, err: cli.NewExitError(message, 1)}
+}
+
+// exitError exits the command with err, its code derives from its type.
+func exitError(err error) error {
+	code := codeError
+	cause := err
+	if e, ok := err.(*builder.ToolchainError); ok {
+		code, cause = codeToolchain, e.Err
+	}
+	if e, ok := cause.(*wix.CommandError); ok {
+		switch e.Err {
+		case context.DeadlineExceeded:
+			code = codeTimeout
+		case context.Canceled:
+			code = codeCanceled
+		}
+	}
+	if os.IsNotExist(err) {
+		code = codeNotFound
+	}
+	if problems, ok := err.(manifest.ValidationError); ok {
+		return &commandError{code: codeInvalid, err: err, problems: problems}
+	}
+	return &commandError{code: code, err: err}
+}
+
+func outputJSON(c *cli.Context) bool {
+	return c.GlobalString("output") == "json"
+}
+
+// setResult records the result document of the command.
+func setResult(c *cli.Context, result interface{}) {
+	c.App.Metadata["result"] = result
+}
+
+// withOutput wraps a command action to print its json document.
+func withOutput(action func(*cli.Context) error) func(*cli.Context) error {
+	return func(c *cli.Context) error {
+		err := action(c)
+		if !outputJSON(c) {
+			return err
+		}
+		doc := document{Command: c.Command.Name, Success: err == nil, Result: c.App.Metadata["result"]}
+		if err != nil {
+			doc.Result = nil
+			doc.Error = &docError{Code: codeError, Message: err.Error()}
+			if e, ok := err.(*commandError); ok {
+				doc.Error.Code = e.code
+				doc.Error.Problems = e.problems
+				if t, ok := e.err.(*builder.ToolchainError); ok {
+					doc.Error.Diagnostics = t.Diagnostics
+					if ce, ok := t.Err.(*wix.CommandError); ok && ce.ExitCode >= 0 {
+						doc.Error.ExitCode = &ce.ExitCode
+					}
+				}
+			}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if e := enc.Encode(doc); e != nil {
+			return cli.NewExitError(e.Error(), 1)
+		}
+		if err != nil {
+			return cli.NewExitError("", 1)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,332 @@
+// Package msidb reads and writes Windows Installer databases.
+//
+// A database is a compound file holding one stream per table, the tables
+// store their strings in a shared string pool and their data column by
+// column.
+package msidb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stirante/go-msi/cfb"
+)
+
+// Column type flags as stored in the _Columns table.
+const (
+	typeValid       = 0x0100
+	typeLocalizable = 0x0200
+	typeNotBinary   = 0x0400
+	typeString      = 0x0800
+	typeNullable    = 0x1000
+	typeKey         = 0x2000
+	typeSizeMask    = 0x00FF
+)
+
+// CLSID is the class identifier of the root storage of an installer database.
+const CLSID = "{000C1084-0000-0000-C000-000000000046}"
+
+// DefaultCodepage is the codepage used to encode the string pool.
+const DefaultCodepage = 1252
+
+// Column describes a table column, its type uses the IDT notation:
+// s72 is a string of 72 characters, an uppercase letter marks a nullable
+// column, l is a localizable string, i2 and i4 are integers and v0 is a
+// binary stream.
+type Column struct {
+	Name string
+	Type string
+	Key  bool
+}
+
+func (c Column) code() (int, error) {
+	if len(c.Type) < 2 {
+		return 0, fmt.Errorf("invalid type %q for column %s", c.Type, c.Name)
+	}
+	size, err := strconv.Atoi(c.Type[1:])
+	if err != nil || size < 0 || size > 255 {
+		return 0, fmt.Errorf("invalid type %q for column %s", c.Type, c.Name)
+	}
+	var code int
+	switch c.Type[0] {
+	case 's', 'S':
+		code = typeValid | typeNotBinary | typeString | size
+	case 'l', 'L':
+		code = typeValid | typeNotBinary | typeString | typeLocalizable | size
+	case 'i', 'I':
+		switch size {
+		case 2:
+			code = typeValid | typeNotBinary | 2
+		case 4:
+			code = typeValid | 4
+		default:
+			return 0, fmt.Errorf("invalid type %q for column %s", c.Type, c.Name)
+		}
+	case 'v', 'V':
+		code = typeValid | typeString
+	default:
+		return 0, fmt.Errorf("invalid type %q for column %s", c.Type, c.Name)
+	}
+	if c.Type[0] >= 'A' && c.Type[0] <= 'Z' {
+		code |= typeNullable
+	}
+	if c.Key {
+		code |= typeKey
+	}
+	return code, nil
+}
+
+func (c Column) isString() bool {
+	return strings.ContainsAny(c.Type[:1], "sSlL")
+}
+
+func (c Column) isStream() bool {
+	return strings.ContainsAny(c.Type[:1], "vV")
+}
+
+func (c Column) width(refSize int) int {
+	switch {
+	case c.isString():
+		return refSize
+	case c.Type[1:] == "4":
+		return 4
+	}
+	return 2
+}
+
+// size returns the maximum length of the strings of the column, 0 when unlimited.
+func (c Column) size() int {
+	size, _ := strconv.Atoi(c.Type[1:])
+	return size
+}
+
+// Table is a named list of columns and its rows,
+// a row value is either nil, a string, an int or a []byte for streams.
+type Table struct {
+	Name    string
+	Columns []Column
+	Rows    [][]interface{}
+	err     error
+}
+
+// Add appends a row to the table, the first string longer than
+// its column is reported by Write.
+func (t *Table) Add(values ...interface{}) {
+	row := make([]interface{}, len(t.Columns))
+	copy(row, values)
+	for i, c := range t.Columns {
+		s, ok := row[i].(string)
+		if ok && c.isString() && c.size() > 0 && len([]rune(s)) > c.size() && t.err == nil {
+			t.err = fmt.Errorf("table %s: column %s value %q is longer than %d characters", t.Name, c.Name, s, c.size())
+		}
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+// Database is an in memory installer database.
+type Database struct {
+	Codepage int
+	Tables   []*Table
+	Streams  map[string][]byte
+	Summary  SummaryInfo
+}
+
+// New returns an empty database.
+func New() *Database {
+	return &Database{
+		Codepage: DefaultCodepage,
+		Streams:  make(map[string][]byte),
+	}
+}
+
+// Table returns the table with the given name,
+// it is created with the given columns when it does not exist yet.
+func (db *Database) Table(name string, columns ...Column) *Table {
+	for _, t := range db.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	t := &Table{Name: name, Columns: columns}
+	db.Tables = append(db.Tables, t)
+	return t
+}
+
+// Write serializes the database to w.
+func (db *Database) Write(w io.Writer) error {
+	clsid, err := cfb.ParseCLSID(CLSID)
+	if err != nil {
+		return err
+	}
+	out := cfb.NewWriter()
+	out.CLSID = clsid
+
+	var tables []*Table
+	for _, t := range db.Tables {
+		if t.err != nil {
+			return t.err
+		}
+		if len(t.Rows) > 0 {
+			tables = append(tables, t)
+		}
+	}
+
+	pool := newStringPool()
+	tablesTable := &Table{Name: "_Tables", Columns: []Column{{"Name", "s64", true}}}
+	columnsTable := &Table{Name: "_Columns", Columns: []Column{
+		{"Table", "s64", true}, {"Number", "i2", true}, {"Name", "s64", false}, {"Type", "i2", false},
+	}}
+	for _, t := range tables {
+		tablesTable.Add(t.Name)
+		for i, c := range t.Columns {
+			code, err := c.code()
+			if err != nil {
+				return fmt.Errorf("table %s: %v", t.Name, err)
+			}
+			columnsTable.Add(t.Name, i+1, c.Name, code)
+		}
+	}
+
+	// Intern every string first so that the reference width is known
+	// before any table stream gets encoded.
+	all := append([]*Table{tablesTable, columnsTable}, tables...)
+	cells := make([][][]int, len(all))
+	for i, t := range all {
+		if cells[i], err = db.intern(pool, t); err != nil {
+			return err
+		}
+	}
+	for i, t := range all {
+		if err := out.Add(EncodeName(t.Name, true), encodeTable(t, cells[i], pool.refSize())); err != nil {
+			return err
+		}
+	}
+
+	poolData, stringData, err := pool.encode(db.Codepage)
+	if err != nil {
+		return err
+	}
+	if err := out.Add(EncodeName("_StringPool", true), poolData); err != nil {
+		return err
+	}
+	if err := out.Add(EncodeName("_StringData", true), stringData); err != nil {
+		return err
+	}
+
+	for name, data := range db.Streams {
+		if err := out.Add(EncodeName(name, false), data); err != nil {
+			return err
+		}
+	}
+	summary, err := db.Summary.encode(db.Codepage)
+	if err != nil {
+		return err
+	}
+	if err := out.Add(SummaryStream, summary); err != nil {
+		return err
+	}
+
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// intern converts the rows of t to their stored integer values,
+// sorted on the primary key columns.
+func (db *Database) intern(pool *stringPool, t *Table) ([][]int, error) {
+	rows := make([][]int, len(t.Rows))
+	for r, row := range t.Rows {
+		cells := make([]int, len(t.Columns))
+		for i, c := range t.Columns {
+			v := row[i]
+			if v == nil {
+				if c.Type[0] >= 'a' {
+					return nil, fmt.Errorf("table %s: column %s cannot be null", t.Name, c.Name)
+				}
+				continue
+			}
+			switch {
+			case c.isString():
+				s, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("table %s: column %s expects a string, got %T", t.Name, c.Name, v)
+				}
+				if s == "" && c.Type[0] >= 'a' {
+					return nil, fmt.Errorf("table %s: column %s cannot be empty", t.Name, c.Name)
+				}
+				cells[i] = pool.ref(s)
+			case c.isStream():
+				data, ok := v.([]byte)
+				if !ok {
+					return nil, fmt.Errorf("table %s: column %s expects a stream, got %T", t.Name, c.Name, v)
+				}
+				db.Streams[streamName(t, row)] = data
+				cells[i] = 1
+			default:
+				n, ok := v.(int)
+				if !ok {
+					return nil, fmt.Errorf("table %s: column %s expects an integer, got %T", t.Name, c.Name, v)
+				}
+				if c.Type[1:] == "2" {
+					if n < -0x7FFF || n > 0x7FFF {
+						return nil, fmt.Errorf("table %s: column %s value %d out of range", t.Name, c.Name, n)
+					}
+					cells[i] = n + 0x8000
+				} else {
+					cells[i] = int(uint32(int32(n)) ^ 0x80000000)
+				}
+			}
+		}
+		rows[r] = cells
+	}
+	sort.SliceStable(rows, func(a, b int) bool {
+		for i, c := range t.Columns {
+			if !c.Key {
+				break
+			}
+			if rows[a][i] != rows[b][i] {
+				return rows[a][i] < rows[b][i]
+			}
+		}
+		return false
+	})
+	return rows, nil
+}
+
+// streamName is the name of the stream holding a binary cell of row,
+// made of the table name and the primary key values.
+func streamName(t *Table, row []interface{}) string {
+	parts := []string{t.Name}
+	for i, c := range t.Columns {
+		if c.Key {
+			parts = append(parts, fmt.Sprint(row[i]))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func encodeTable(t *Table, rows [][]int, refSize int) []byte {
+	var b bytes.Buffer
+	buf := make([]byte, 4)
+	for i, c := range t.Columns {
+		width := c.width(refSize)
+		for _, row := range rows {
+			v := uint32(row[i])
+			switch width {
+			case 2:
+				binary.LittleEndian.PutUint16(buf, uint16(v))
+			case 3:
+				binary.LittleEndian.PutUint16(buf, uint16(v))
+				buf[2] = byte(v >> 16)
+			case 4:
+				binary.LittleEndian.PutUint32(buf, v)
+			}
+			b.Write(buf[:width])
+		}
+	}
+	return b.Bytes()
+}
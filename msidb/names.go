@@ -0,0 +1,59 @@
+package msidb
+
+import "strings"
+
+// Windows Installer packs stream names into the compound file by mapping
+// pairs of characters from a 64 symbols alphabet onto a single UTF-16 code
+// unit, this keeps most names below the 31 characters limit.
+const (
+	namePairBase   = 0x3800
+	nameSingleBase = 0x4800
+	nameTableMark  = 0x4840
+	nameAlphabet   = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz._"
+)
+
+// EncodeName converts a table or stream name to its compound file stream name.
+func EncodeName(name string, table bool) string {
+	var out []rune
+	if table {
+		out = append(out, nameTableMark)
+	}
+	rs := []rune(name)
+	for i := 0; i < len(rs); i++ {
+		c := strings.IndexRune(nameAlphabet, rs[i])
+		if c < 0 {
+			out = append(out, rs[i])
+			continue
+		}
+		if i+1 < len(rs) {
+			if n := strings.IndexRune(nameAlphabet, rs[i+1]); n >= 0 {
+				out = append(out, rune(namePairBase+c+n<<6))
+				i++
+				continue
+			}
+		}
+		out = append(out, rune(nameSingleBase+c))
+	}
+	return string(out)
+}
+
+// DecodeName converts a compound file stream name back to its table or
+// stream name, it tells whether the name was the one of a table.
+func DecodeName(encoded string) (string, bool) {
+	var out []byte
+	table := false
+	for i, r := range []rune(encoded) {
+		switch {
+		case i == 0 && r == nameTableMark:
+			table = true
+		case r >= namePairBase && r < nameSingleBase:
+			c := r - namePairBase
+			out = append(out, nameAlphabet[c&0x3F], nameAlphabet[(c>>6)&0x3F])
+		case r >= nameSingleBase && r < nameTableMark:
+			out = append(out, nameAlphabet[r-nameSingleBase])
+		default:
+			out = append(out, string(r)...)
+		}
+	}
+	return string(out), table
+}
@@ -0,0 +1,179 @@
+package msidb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stirante/go-msi/cfb"
+)
+
+// Read parses an installer database,
+// streams not used by tables nor by binary cells are kept in Streams.
+func Read(data []byte) (*Database, error) {
+	file, err := cfb.NewReader(data)
+	if err != nil {
+		return nil, err
+	}
+	db := New()
+	tableStreams := make(map[string][]byte)
+	for _, name := range file.Streams() {
+		b, _ := file.Stream(name)
+		if name == SummaryStream {
+			if db.Summary, err = decodeSummary(b); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		decoded, table := DecodeName(name)
+		if table {
+			tableStreams[decoded] = b
+		} else {
+			db.Streams[decoded] = b
+		}
+	}
+
+	strs, codepage, refSize, err := decodeStringPool(tableStreams["_StringPool"], tableStreams["_StringData"])
+	if err != nil {
+		return nil, err
+	}
+	db.Codepage = codepage
+	str := func(id int) (string, error) {
+		if id < 0 || id >= len(strs) {
+			return "", fmt.Errorf("invalid string reference %d", id)
+		}
+		return strs[id], nil
+	}
+
+	tablesTable := &Table{Name: "_Tables", Columns: []Column{{"Name", "s64", true}}}
+	columnsTable := &Table{Name: "_Columns", Columns: []Column{
+		{"Table", "s64", true}, {"Number", "i2", true}, {"Name", "s64", false}, {"Type", "i2", false},
+	}}
+	if err := db.decodeTable(tablesTable, tableStreams["_Tables"], refSize, str); err != nil {
+		return nil, err
+	}
+	if err := db.decodeTable(columnsTable, tableStreams["_Columns"], refSize, str); err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string][]Column)
+	numbers := make(map[string][]int)
+	for _, row := range columnsTable.Rows {
+		table, _ := row[0].(string)
+		number, _ := row[1].(int)
+		name, _ := row[2].(string)
+		code, _ := row[3].(int)
+		columns[table] = append(columns[table], columnFromCode(name, code))
+		numbers[table] = append(numbers[table], number)
+	}
+	for _, row := range tablesTable.Rows {
+		name, _ := row[0].(string)
+		cols := columns[name]
+		nums := numbers[name]
+		sort.Sort(byNumber{cols, nums})
+		t := db.Table(name, cols...)
+		if err := db.decodeTable(t, tableStreams[name], refSize, str); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+type byNumber struct {
+	cols []Column
+	nums []int
+}
+
+func (b byNumber) Len() int           { return len(b.cols) }
+func (b byNumber) Less(i, j int) bool { return b.nums[i] < b.nums[j] }
+func (b byNumber) Swap(i, j int) {
+	b.cols[i], b.cols[j] = b.cols[j], b.cols[i]
+	b.nums[i], b.nums[j] = b.nums[j], b.nums[i]
+}
+
+// columnFromCode converts a _Columns type to its IDT notation.
+func columnFromCode(name string, code int) Column {
+	var typ string
+	size := code & typeSizeMask
+	switch {
+	case code&typeString != 0 && code&typeNotBinary == 0:
+		typ = "v0"
+	case code&typeString != 0 && code&typeLocalizable != 0:
+		typ = fmt.Sprintf("l%d", size)
+	case code&typeString != 0:
+		typ = fmt.Sprintf("s%d", size)
+	case size == 4:
+		typ = "i4"
+	default:
+		typ = "i2"
+	}
+	if code&typeNullable != 0 {
+		typ = strings.ToUpper(typ[:1]) + typ[1:]
+	}
+	return Column{Name: name, Type: typ, Key: code&typeKey != 0}
+}
+
+func (db *Database) decodeTable(t *Table, data []byte, refSize int, str func(int) (string, error)) error {
+	rowSize := 0
+	for _, c := range t.Columns {
+		rowSize += c.width(refSize)
+	}
+	if rowSize == 0 {
+		return nil
+	}
+	if len(data)%rowSize != 0 {
+		return fmt.Errorf("table %s: stream size %d is not a multiple of the row size %d", t.Name, len(data), rowSize)
+	}
+	count := len(data) / rowSize
+	t.Rows = make([][]interface{}, count)
+	for r := range t.Rows {
+		t.Rows[r] = make([]interface{}, len(t.Columns))
+	}
+	offset := 0
+	for i, c := range t.Columns {
+		width := c.width(refSize)
+		for r := 0; r < count; r++ {
+			b := data[offset+r*width:]
+			var v uint32
+			switch width {
+			case 2:
+				v = uint32(binary.LittleEndian.Uint16(b))
+			case 3:
+				v = uint32(binary.LittleEndian.Uint16(b)) | uint32(b[2])<<16
+			case 4:
+				v = binary.LittleEndian.Uint32(b)
+			}
+			if v == 0 {
+				continue
+			}
+			switch {
+			case c.isString():
+				s, err := str(int(v))
+				if err != nil {
+					return fmt.Errorf("table %s: column %s: %v", t.Name, c.Name, err)
+				}
+				t.Rows[r][i] = s
+			case c.isStream():
+			case width == 2:
+				t.Rows[r][i] = int(v) - 0x8000
+			default:
+				t.Rows[r][i] = int(int32(v ^ 0x80000000))
+			}
+		}
+		offset += count * width
+	}
+	for i, c := range t.Columns {
+		if !c.isStream() {
+			continue
+		}
+		for _, row := range t.Rows {
+			name := streamName(t, row)
+			if b, ok := db.Streams[name]; ok {
+				row[i] = b
+				delete(db.Streams, name)
+			}
+		}
+	}
+	return nil
+}
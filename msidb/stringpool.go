@@ -0,0 +1,158 @@
+package msidb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// longRefs flags a string pool whose references are stored on 3 bytes.
+const longRefs = 0x8000
+
+// codepages maps the codepages of the database strings to their encoding,
+// the neutral codepage 0 is handled as Windows-1252.
+var codepages = map[int]encoding.Encoding{
+	0:     charmap.Windows1252,
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	65001: encoding.Nop,
+}
+
+func codepageEncoding(codepage int) (encoding.Encoding, error) {
+	if e, ok := codepages[codepage]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("unsupported codepage %d", codepage)
+}
+
+// stringPool interns the strings of the database, id 0 is the null string.
+type stringPool struct {
+	ids     map[string]int
+	strings []string
+	refs    []int
+}
+
+func newStringPool() *stringPool {
+	return &stringPool{ids: make(map[string]int), strings: []string{""}, refs: []int{0}}
+}
+
+// id returns the id of s, adding it to the pool if needed.
+func (p *stringPool) id(s string) int {
+	if s == "" {
+		return 0
+	}
+	if id, ok := p.ids[s]; ok {
+		return id
+	}
+	id := len(p.strings)
+	p.ids[s] = id
+	p.strings = append(p.strings, s)
+	p.refs = append(p.refs, 0)
+	return id
+}
+
+// ref interns s and counts a reference to it.
+func (p *stringPool) ref(s string) int {
+	id := p.id(s)
+	if id != 0 {
+		p.refs[id]++
+	}
+	return id
+}
+
+// refSize returns the width in bytes of a string reference in table streams.
+func (p *stringPool) refSize() int {
+	if len(p.strings) > 0xFFFF {
+		return 3
+	}
+	return 2
+}
+
+// encode serializes the pool into the _StringPool and _StringData streams.
+func (p *stringPool) encode(codepage int) ([]byte, []byte, error) {
+	le := binary.LittleEndian
+	pool := make([]byte, 4, 4+len(p.strings)*4)
+	le.PutUint16(pool[0:], uint16(codepage))
+	high := uint16(codepage >> 16)
+	if p.refSize() == 3 {
+		high |= longRefs
+	}
+	le.PutUint16(pool[2:], high)
+
+	e, err := codepageEncoding(codepage)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc := e.NewEncoder()
+	var data []byte
+	entry := make([]byte, 4)
+	for id := 1; id < len(p.strings); id++ {
+		b, err := enc.Bytes([]byte(p.strings[id]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot encode %q with codepage %d: %v", p.strings[id], codepage, err)
+		}
+		refs := p.refs[id]
+		if refs > 0xFFFF {
+			refs = 0xFFFF
+		}
+		if len(b) > 0xFFFF {
+			le.PutUint16(entry[0:], 0)
+			le.PutUint16(entry[2:], uint16(len(b)>>16))
+			pool = append(pool, entry...)
+		}
+		le.PutUint16(entry[0:], uint16(len(b)))
+		le.PutUint16(entry[2:], uint16(refs))
+		pool = append(pool, entry...)
+		data = append(data, b...)
+	}
+	return pool, data, nil
+}
+
+// decodeStringPool parses the _StringPool and _StringData streams,
+// it returns the strings indexed by id, the codepage and the width of
+// string references.
+func decodeStringPool(pool, data []byte) ([]string, int, int, error) {
+	if len(pool) < 4 {
+		return []string{""}, 0, 2, nil
+	}
+	le := binary.LittleEndian
+	codepage := int(le.Uint16(pool[0:])) | int(le.Uint16(pool[2:])&^longRefs)<<16
+	size := 2
+	if le.Uint16(pool[2:])&longRefs != 0 {
+		size = 3
+	}
+	e, err := codepageEncoding(codepage)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	dec := e.NewDecoder()
+	strs := []string{""}
+	offset := 0
+	for i := 4; i+4 <= len(pool); i += 4 {
+		length := int(le.Uint16(pool[i:]))
+		refs := le.Uint16(pool[i+2:])
+		if length == 0 && refs != 0 && i+8 <= len(pool) {
+			length = int(refs)<<16 | int(le.Uint16(pool[i+4:]))
+			i += 4
+		}
+		if offset+length > len(data) {
+			return nil, 0, 0, fmt.Errorf("string pool is truncated")
+		}
+		s, err := dec.Bytes(data[offset : offset+length])
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		strs = append(strs, string(s))
+		offset += length
+	}
+	return strs, codepage, size, nil
+}
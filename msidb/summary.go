@@ -0,0 +1,248 @@
+package msidb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/stirante/go-msi/cfb"
+)
+
+// SummaryStream is the name of the summary information stream.
+const SummaryStream = "\x05SummaryInformation"
+
+// Summary information property identifiers.
+const (
+	PIDCodepage   = 1
+	PIDTitle      = 2
+	PIDSubject    = 3
+	PIDAuthor     = 4
+	PIDKeywords   = 5
+	PIDComments   = 6
+	PIDTemplate   = 7
+	PIDLastAuthor = 8
+	PIDRevision   = 9
+	PIDCreateTime = 12
+	PIDSaveTime   = 13
+	PIDPageCount  = 14
+	PIDWordCount  = 15
+	PIDAppName    = 18
+	PIDSecurity   = 19
+)
+
+// Property variant types used by the summary information stream.
+const (
+	vtI2       = 2
+	vtI4       = 3
+	vtLPStr    = 30
+	vtFileTime = 64
+)
+
+// summaryFMTID is the FMTID_SummaryInformation format identifier.
+const summaryFMTID = "{F29F85E0-4FF9-1068-AB91-08002B27B3D9}"
+
+// SummaryInfo holds the summary information properties of a database.
+type SummaryInfo struct {
+	Title      string
+	Subject    string
+	Author     string
+	Keywords   string
+	Comments   string
+	Template   string // platform and languages, for example x64;1033
+	LastAuthor string
+	Revision   string // the package code
+	CreateTime time.Time
+	SaveTime   time.Time
+	PageCount  int // minimum installer version
+	WordCount  int // source image flags
+	AppName    string
+	Security   int
+}
+
+type property struct {
+	id    uint32
+	value interface{}
+}
+
+func (s *SummaryInfo) properties(codepage int) []property {
+	props := []property{{PIDCodepage, int16(codepage)}}
+	for _, p := range []struct {
+		id uint32
+		v  string
+	}{
+		{PIDTitle, s.Title}, {PIDSubject, s.Subject}, {PIDAuthor, s.Author},
+		{PIDKeywords, s.Keywords}, {PIDComments, s.Comments}, {PIDTemplate, s.Template},
+		{PIDLastAuthor, s.LastAuthor}, {PIDRevision, s.Revision}, {PIDAppName, s.AppName},
+	} {
+		if p.v != "" {
+			props = append(props, property{p.id, p.v})
+		}
+	}
+	if !s.CreateTime.IsZero() {
+		props = append(props, property{PIDCreateTime, s.CreateTime})
+	}
+	if !s.SaveTime.IsZero() {
+		props = append(props, property{PIDSaveTime, s.SaveTime})
+	}
+	props = append(props,
+		property{PIDPageCount, int32(s.PageCount)},
+		property{PIDWordCount, int32(s.WordCount)},
+		property{PIDSecurity, int32(s.Security)},
+	)
+	sort.Slice(props, func(i, j int) bool { return props[i].id < props[j].id })
+	return props
+}
+
+// encode serializes the summary information as a property set stream.
+func (s *SummaryInfo) encode(codepage int) ([]byte, error) {
+	le := binary.LittleEndian
+	props := s.properties(codepage)
+	e, err := codepageEncoding(codepage)
+	if err != nil {
+		return nil, err
+	}
+	enc := e.NewEncoder()
+
+	var values bytes.Buffer
+	offsets := make([]uint32, len(props))
+	base := 8 + 8*len(props)
+	for i, p := range props {
+		offsets[i] = uint32(base + values.Len())
+		switch v := p.value.(type) {
+		case int16:
+			binary.Write(&values, le, []uint32{vtI2, uint32(uint16(v))})
+		case int32:
+			binary.Write(&values, le, []uint32{vtI4, uint32(v)})
+		case string:
+			b, err := enc.Bytes([]byte(v))
+			if err != nil {
+				return nil, fmt.Errorf("cannot encode summary property %q: %v", v, err)
+			}
+			b = append(b, 0)
+			binary.Write(&values, le, []uint32{vtLPStr, uint32(len(b))})
+			values.Write(b)
+			for values.Len()%4 != 0 {
+				values.WriteByte(0)
+			}
+		case time.Time:
+			binary.Write(&values, le, uint32(vtFileTime))
+			binary.Write(&values, le, toFileTime(v))
+		}
+	}
+
+	var b bytes.Buffer
+	fmtid, err := cfb.ParseCLSID(summaryFMTID)
+	if err != nil {
+		return nil, err
+	}
+	binary.Write(&b, le, []uint16{0xFFFE, 0})
+	binary.Write(&b, le, uint32(0x00020006))
+	b.Write(make([]byte, 16))
+	binary.Write(&b, le, uint32(1))
+	b.Write(fmtid[:])
+	binary.Write(&b, le, uint32(48))
+	binary.Write(&b, le, []uint32{uint32(base + values.Len()), uint32(len(props))})
+	for i, p := range props {
+		binary.Write(&b, le, []uint32{p.id, offsets[i]})
+	}
+	b.Write(values.Bytes())
+	return b.Bytes(), nil
+}
+
+// fileTimeEpoch is the number of 100ns intervals between 1601 and 1970.
+const fileTimeEpoch = 116444736000000000
+
+func toFileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + fileTimeEpoch
+}
+
+// decodeSummary parses a summary information property set stream.
+func decodeSummary(data []byte) (SummaryInfo, error) {
+	var s SummaryInfo
+	le := binary.LittleEndian
+	if len(data) < 48 || le.Uint16(data) != 0xFFFE {
+		return s, fmt.Errorf("invalid summary information stream")
+	}
+	base := int(le.Uint32(data[44:]))
+	if base+8 > len(data) {
+		return s, fmt.Errorf("invalid summary information stream")
+	}
+	count := int(le.Uint32(data[base+4:]))
+	if base+8+count*8 > len(data) {
+		return s, fmt.Errorf("invalid summary information stream")
+	}
+	values := make(map[uint32]interface{})
+	codepage := DefaultCodepage
+	for i := 0; i < count; i++ {
+		id := le.Uint32(data[base+8+i*8:])
+		off := base + int(le.Uint32(data[base+12+i*8:]))
+		if off+8 > len(data) {
+			return s, fmt.Errorf("invalid summary information property %d", id)
+		}
+		v := data[off+4:]
+		switch le.Uint32(data[off:]) {
+		case vtI2:
+			values[id] = int(int16(le.Uint16(v)))
+		case vtI4:
+			values[id] = int(int32(le.Uint32(v)))
+		case vtLPStr:
+			n := int(le.Uint32(v))
+			if 4+n > len(v) {
+				return s, fmt.Errorf("invalid summary information property %d", id)
+			}
+			values[id] = bytes.TrimRight(v[4:4+n], "\x00")
+		case vtFileTime:
+			values[id] = fromFileTime(le.Uint64(v))
+		}
+	}
+	if cp, ok := values[PIDCodepage].(int); ok {
+		codepage = int(uint16(cp))
+	}
+	e, err := codepageEncoding(codepage)
+	if err != nil {
+		return s, err
+	}
+	dec := e.NewDecoder()
+	str := func(id uint32) string {
+		if b, ok := values[id].([]byte); ok {
+			if d, err := dec.Bytes(b); err == nil {
+				return string(d)
+			}
+			return string(b)
+		}
+		return ""
+	}
+	num := func(id uint32) int {
+		n, _ := values[id].(int)
+		return n
+	}
+	date := func(id uint32) time.Time {
+		t, _ := values[id].(time.Time)
+		return t
+	}
+	return SummaryInfo{
+		Title:      str(PIDTitle),
+		Subject:    str(PIDSubject),
+		Author:     str(PIDAuthor),
+		Keywords:   str(PIDKeywords),
+		Comments:   str(PIDComments),
+		Template:   str(PIDTemplate),
+		LastAuthor: str(PIDLastAuthor),
+		Revision:   str(PIDRevision),
+		CreateTime: date(PIDCreateTime),
+		SaveTime:   date(PIDSaveTime),
+		PageCount:  num(PIDPageCount),
+		WordCount:  num(PIDWordCount),
+		AppName:    str(PIDAppName),
+		Security:   num(PIDSecurity),
+	}, nil
+}
+
+func fromFileTime(ft uint64) time.Time {
+	if ft < fileTimeEpoch {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-fileTimeEpoch)*100).UTC()
+}
@@ -0,0 +1,887 @@
+// Package native builds MSI packages straight from a wix manifest,
+// without the WiX toolset.
+//
+// It produces the same tables the WiX templates would, except for the
+// installer dialogs: the package only has the basic Windows Installer UI.
+package native
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stirante/go-msi/cab"
+	"github.com/stirante/go-msi/manifest"
+	"github.com/stirante/go-msi/msidb"
+)
+
+// cabinet is the name of the embedded cabinet stream.
+const cabinet = "product.cab"
+
+// Custom action type flags.
+const (
+	caExe                   = 34
+	caContinue              = 0x40
+	caAsync                 = 0x80
+	caInScript              = 0x400
+	caNoImpersonate         = 0x800
+	componentPermanent      = 0x10
+	componentNeverOverwrite = 0x80
+	componentWin64          = 256
+	featureUIDisallowAbsent = 0x10
+	fileReadOnly            = 0x1
+	fileHidden              = 0x2
+	fileVital               = 512
+	locatorRaw              = 2
+	removeOnUninstall       = 2
+	locator64               = 0x10
+	serviceOwnProc          = 0x10
+	serviceInteractive      = 0x100
+	serviceErrNormal        = 1
+	serviceErrVital         = 0x8000
+)
+
+var registryRoots = map[string]int{
+	"HKMU": -1,
+	"HKCR": 0,
+	"HKCU": 1,
+	"HKLM": 2,
+	"HKU":  3,
+}
+
+// events of the ServiceControl table on install, shifted by 4 on uninstall
+const (
+	serviceControlStart  = 0x01
+	serviceControlStop   = 0x02
+	serviceControlDelete = 0x08
+)
+
+// events of the service configuration tables
+const (
+	serviceConfigOnInstall   = 0x01
+	serviceConfigOnReinstall = 0x04
+)
+
+// service configurations of the MsiServiceConfig table
+const (
+	serviceConfigDelayedAutoStart = 3
+	serviceConfigSid              = 5
+	serviceConfigPrivileges       = 6
+	serviceConfigPreshutdown      = 7
+)
+
+var serviceSidTypes = map[string]int{
+	"none":         0,
+	"unrestricted": 1,
+	"restricted":   3,
+}
+
+var recoveryActions = map[string]int{
+	"":            0,
+	"none":        0,
+	"restart":     1,
+	"reboot":      2,
+	"run-command": 3,
+}
+
+var serviceStarts = map[string]int{
+	"auto":     2,
+	"demand":   3,
+	"disabled": 4,
+}
+
+type builder struct {
+	wixFile    *manifest.WixManifest
+	base       string
+	win64      bool
+	db         *msidb.Database
+	cab        *cab.Writer
+	namespace  uuid.UUID
+	secure     []string
+	sequence   int
+	shortNames map[string]map[string]bool
+}
+
+// Make builds the msi package described by the normalized wixFile into msiFile.
+// Relative file paths of the manifest are resolved against base.
+func Make(wixFile *manifest.WixManifest, base, arch, msiFile string) error {
+	win64, err := is64(arch)
+	if err != nil {
+		return err
+	}
+	namespace, err := uuid.Parse(wixFile.UpgradeCode)
+	if err != nil {
+		return fmt.Errorf("invalid upgrade code %q: %v", wixFile.UpgradeCode, err)
+	}
+	level, err := cab.Compression(wixFile.Compression)
+	if err != nil {
+		return err
+	}
+	b := &builder{
+		wixFile:    wixFile,
+		base:       base,
+		win64:      win64,
+		db:         msidb.New(),
+		cab:        &cab.Writer{Level: &level},
+		namespace:  namespace,
+		shortNames: make(map[string]map[string]bool),
+	}
+	for _, step := range []func() error{
+		b.directories,
+		b.files,
+		b.environments,
+		b.serviceControls,
+		b.registries,
+		b.shortcuts,
+		b.dataDirectories,
+		b.hooks,
+		b.properties,
+		b.feature,
+		b.upgrade,
+		b.media,
+	} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	b.sequences()
+	b.summary()
+
+	var buf bytes.Buffer
+	if err := b.db.Write(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(msiFile, buf.Bytes(), 0644)
+}
+
+func is64(arch string) (bool, error) {
+	switch arch {
+	case "", "386", "x86":
+		return false, nil
+	case "amd64", "x64":
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported architecture %q", arch)
+}
+
+func guid(id string) string {
+	return "{" + strings.ToUpper(strings.Trim(id, "{}")) + "}"
+}
+
+// componentGUID derives a stable component code from its key path,
+// the same way WiX does for Guid="*".
+func (b *builder) componentGUID(keyPath string) string {
+	return guid(uuid.NewSHA1(b.namespace, []byte(strings.ToLower(keyPath))).String())
+}
+
+func (b *builder) component(id, dir, keyPath, condition, guidSeed string, attributes int) {
+	if b.win64 {
+		attributes |= componentWin64
+	}
+	b.db.Table("Component", componentColumns...).Add(id, b.componentGUID(guidSeed), dir, attributes, optional(condition), optional(keyPath))
+}
+
+func (b *builder) programFiles() string {
+	if b.win64 {
+		return "ProgramFiles64Folder"
+	}
+	return "ProgramFilesFolder"
+}
+
+// installRoot returns the msi directory of the root of the install directory.
+func (b *builder) installRoot() string {
+	if b.wixFile.InstallRoot == manifest.ProgramFiles {
+		return b.programFiles()
+	}
+	return b.wixFile.InstallRoot
+}
+
+func (b *builder) directories() error {
+	t := b.db.Table("Directory", directoryColumns...)
+	t.Add("TARGETDIR", nil, "SourceDir")
+	t.Add(b.programFiles(), "TARGETDIR", ".")
+	t.Add("ProgramMenuFolder", "TARGETDIR", ".")
+	t.Add("DesktopFolder", "TARGETDIR", ".")
+	for _, root := range b.wixFile.RootFolders {
+		if root != b.programFiles() {
+			t.Add(root, "TARGETDIR", ".")
+		}
+	}
+	parent := b.installRoot()
+	for _, f := range b.wixFile.InstallFolders {
+		t.Add(f.ID, parent, b.longName(parent, f.Name))
+		parent = f.ID
+	}
+	var add func(parent string, dirs []manifest.Directory)
+	add = func(parent string, dirs []manifest.Directory) {
+		for _, d := range dirs {
+			id := d.Folder
+			if d.Name == "" {
+				t.Add(id, parent, ".")
+			} else {
+				t.Add(id, parent, b.longName(parent, d.Name))
+			}
+			add(id, d.Directories)
+		}
+	}
+	for _, d := range b.wixFile.Directories {
+		if d.Root != "" {
+			add(d.Root, []manifest.Directory{d})
+		} else {
+			add("INSTALLDIR", []manifest.Directory{d})
+		}
+	}
+	var data func(parent string, folders []manifest.DataFolder)
+	data = func(parent string, folders []manifest.DataFolder) {
+		for _, f := range folders {
+			t.Add(f.ID, parent, b.longName(parent, f.Name))
+			data(f.ID, f.Folders)
+		}
+	}
+	for _, root := range b.wixFile.DataFolders {
+		data(root.ID, root.Folders)
+	}
+	return nil
+}
+
+func (b *builder) files() error {
+	var add func(dir, path string, d manifest.Directory) error
+	add = func(dir, path string, d manifest.Directory) error {
+		for _, f := range d.Files {
+			if err := b.file(dir, path, f); err != nil {
+				return err
+			}
+		}
+		for _, sub := range d.Directories {
+			id, p := sub.Folder, path
+			if sub.Root != "" {
+				p = sub.Root
+			}
+			if sub.Name != "" {
+				p += `\` + sub.Name
+			}
+			if err := add(id, p, sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return add("INSTALLDIR", "INSTALLDIR", b.wixFile.Directory)
+}
+
+func (b *builder) file(dir, path string, f manifest.File) error {
+	src := f.Path
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(b.base, src)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	name := f.InstallName()
+	component := "ApplicationFiles_" + f.ID
+	id := "ApplicationFile_" + f.ID
+	componentAttributes := 0
+	if f.Permanent == "yes" {
+		componentAttributes |= componentPermanent
+	}
+	if f.NeverOverwrite == "yes" {
+		componentAttributes |= componentNeverOverwrite
+	}
+	b.component(component, dir, id, f.Condition, path+`\`+name, componentAttributes)
+	attributes := 0
+	if f.ReadOnly == "yes" {
+		attributes |= fileReadOnly
+	}
+	if f.Hidden == "yes" {
+		attributes |= fileHidden
+	}
+	if f.Vital != "no" {
+		attributes |= fileVital
+	}
+	b.sequence++
+	b.db.Table("File", fileColumns...).Add(id, component, b.longName(dir, name), len(data), nil, nil, attributes, b.sequence)
+	b.cab.Add(id, data, info.ModTime())
+
+	for _, s := range f.AllServices() {
+		start, ok := serviceStarts[s.Start]
+		if !ok {
+			return fmt.Errorf("invalid start %q for service %s", s.Start, s.Name)
+		}
+		var deps interface{}
+		if len(s.Dependencies) > 0 {
+			deps = strings.Join(s.Dependencies, "[~]") + "[~][~]"
+		}
+		typ, errorControl := serviceOwnProc, serviceErrNormal
+		if s.Interactive == "yes" {
+			typ |= serviceInteractive
+		}
+		if s.Vital == "yes" {
+			errorControl |= serviceErrVital
+		}
+		var password interface{}
+		if s.Password != "" {
+			password = "[" + s.Password + "]"
+		}
+		b.db.Table("ServiceInstall", serviceInstallColumns...).Add(
+			"ServiceInstall_"+s.ID, s.Name, optional(s.DisplayName),
+			typ, start, errorControl, nil, deps, s.StartName, password,
+			optional(s.Arguments), component, optional(s.Description))
+		// start on install, stop on install and uninstall, remove on uninstall
+		b.db.Table("ServiceControl", serviceControlColumns...).Add(
+			"ServiceControl_"+s.ID, s.Name, serviceControlStart|serviceControlStop|serviceControlStop<<4|serviceControlDelete<<4, nil, nil, component)
+		b.serviceConfig(component, s)
+		if e := s.EventSource; e != nil {
+			// the key is deleted on uninstall, errors, warnings and information are logged
+			registry := b.db.Table("Registry", registryColumns...)
+			registry.Add("EventSource_"+s.ID, registryRoots["HKLM"], e.Key, "-", nil, component)
+			registry.Add("EventSourceFile_"+s.ID, registryRoots["HKLM"], e.Key, "EventMessageFile", "#%"+e.MessageFile, component)
+			registry.Add("EventSourceTypes_"+s.ID, registryRoots["HKLM"], e.Key, "TypesSupported", "#7", component)
+		}
+	}
+	return nil
+}
+
+// serviceConfig mirrors the ServiceConfig and util:ServiceConfig elements of
+// the WiX templates with the MsiServiceConfig and MsiServiceConfigFailureActions
+// tables of Windows Installer 5, applied on install and reinstall.
+func (b *builder) serviceConfig(component string, s *manifest.Service) {
+	id := s.ID
+	configs := b.db.Table("MsiServiceConfig", serviceConfigColumns...)
+	if s.Delayed {
+		configs.Add("ServiceConfig_"+id, s.Name, serviceConfigOnInstall|serviceConfigOnReinstall, serviceConfigDelayedAutoStart, "1", component)
+	}
+	if sid, ok := serviceSidTypes[s.SidType]; ok {
+		configs.Add("ServiceSid_"+id, s.Name, serviceConfigOnInstall|serviceConfigOnReinstall, serviceConfigSid, strconv.Itoa(sid), component)
+	}
+	if len(s.Privileges) > 0 {
+		configs.Add("ServicePrivileges_"+id, s.Name, serviceConfigOnInstall|serviceConfigOnReinstall, serviceConfigPrivileges, strings.Join(s.Privileges, "[~]"), component)
+	}
+	if s.PreshutdownTimeout > 0 {
+		configs.Add("ServicePreshutdown_"+id, s.Name, serviceConfigOnInstall|serviceConfigOnReinstall, serviceConfigPreshutdown, strconv.Itoa(s.PreshutdownTimeout), component)
+	}
+	if r := s.Recovery; r != nil {
+		var actions, delays []string
+		for _, a := range []string{r.First, r.Second, r.Subsequent} {
+			delay := 0
+			if a == "restart" {
+				delay = r.RestartDelay * 1000
+			}
+			actions = append(actions, strconv.Itoa(recoveryActions[a]))
+			delays = append(delays, strconv.Itoa(delay))
+		}
+		var command interface{}
+		if r.Command != "" {
+			command = manifest.QuoteCommand(r.Command)
+		}
+		b.db.Table("MsiServiceConfigFailureActions", serviceFailureActionsColumns...).Add(
+			"ServiceFailureActions_"+id, s.Name, serviceConfigOnInstall|serviceConfigOnReinstall, r.ResetPeriod*24*3600, nil, command,
+			strings.Join(actions, "[~]"), strings.Join(delays, "[~]"), component)
+	}
+}
+
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// serviceControls mirrors the components of the existing services
+// controlled by the package, their key path is a registry value.
+func (b *builder) serviceControls() error {
+	root := b.wixFile.RegistryRoot()
+	for _, c := range b.wixFile.ServiceControls {
+		component := "ServiceControls_" + c.ID
+		reg := "ControlsRegistry_" + c.ID
+		b.component(component, "TARGETDIR", reg, c.Condition, root+`\Software\[Manufacturer]\[ProductName]\servicecontrol_`+c.ID, 0)
+		b.db.Table("Registry", registryColumns...).Add(reg, registryRoots[root],
+			`Software\[Manufacturer]\[ProductName]`, "servicecontrol_"+c.ID, "#1", component)
+
+		event := 0
+		for _, e := range []struct {
+			when    string
+			install int
+		}{
+			{c.Start, serviceControlStart},
+			{c.Stop, serviceControlStop},
+			{c.Remove, serviceControlDelete},
+		} {
+			switch e.when {
+			case "install":
+				event |= e.install
+			case "uninstall":
+				event |= e.install << 4
+			case "both":
+				event |= e.install | e.install<<4
+			}
+		}
+		wait := 1
+		if c.Wait == "no" {
+			wait = 0
+		}
+		b.db.Table("ServiceControl", serviceControlColumns...).Add(
+			"ServiceControl_"+c.ID, c.Name, event, nil, wait, component)
+	}
+	return nil
+}
+
+func (b *builder) environments() error {
+	root := b.wixFile.RegistryRoot()
+	for _, e := range b.wixFile.Environments {
+		component := "Environments_" + e.ID
+		reg := "EnvironmentsRegistry_" + e.ID
+		b.component(component, "TARGETDIR", reg, e.Condition, root+`\Software\[Manufacturer]\[ProductName]\envvar_`+e.ID, 0)
+		b.db.Table("Registry", registryColumns...).Add(reg, registryRoots[root],
+			`Software\[Manufacturer]\[ProductName]`, "envvar_"+e.ID, "#1", component)
+
+		name := ""
+		switch e.Action {
+		case "set", "":
+			name += "="
+		case "create":
+			name += "+"
+		case "remove":
+			name += "!"
+		default:
+			return fmt.Errorf("invalid action %q for environment %s", e.Action, e.Name)
+		}
+		if e.Permanent != "yes" {
+			name += "-"
+		}
+		if e.System == "yes" && b.wixFile.Scope() == manifest.PerMachine {
+			name += "*"
+		}
+		value := e.Value
+		switch e.Part {
+		case "first":
+			value += ";[~]"
+		case "last":
+			value = "[~];" + value
+		case "all", "":
+		default:
+			return fmt.Errorf("invalid part %q for environment %s", e.Part, e.Name)
+		}
+		b.db.Table("Environment", environmentColumns...).Add("Environment_"+e.ID, name+e.Name, value, component)
+	}
+	return nil
+}
+
+// registryValue formats a value the way the Registry table expects it.
+func registryValue(typ, value string) (interface{}, error) {
+	switch typ {
+	case "string", "":
+		if value == "" {
+			return nil, nil
+		}
+		if strings.HasPrefix(value, "#") {
+			value = "#" + value
+		}
+		return value, nil
+	case "integer":
+		return "#" + value, nil
+	case "expandable":
+		return "#%" + value, nil
+	case "binary":
+		return "#x" + value, nil
+	case "multiString":
+		return "[~]" + value + "[~]", nil
+	}
+	return nil, fmt.Errorf("invalid registry value type %q", typ)
+}
+
+func (b *builder) registries() error {
+	t := b.db.Table("Registry", registryColumns...)
+	for _, r := range b.wixFile.Registries {
+		root, ok := registryRoots[r.Root]
+		if !ok {
+			return fmt.Errorf("invalid registry root %q in %q", r.Root, r.Path)
+		}
+		component := "RegistryEntries_" + r.ID
+		keyPath := ""
+		for j, v := range r.Values {
+			id := fmt.Sprintf("RegistryEntry_%s_%d", r.ID, j)
+			value, err := registryValue(v.Type, v.Value)
+			if err != nil {
+				return err
+			}
+			t.Add(id, root, r.Key, optional(v.Name), value, component)
+			if j == 0 {
+				keyPath = id
+			}
+		}
+		b.component(component, "TARGETDIR", keyPath, r.Condition, r.Path, 0)
+	}
+
+	info := manifest.Info{}
+	if b.wixFile.Info != nil {
+		info = *b.wixFile.Info
+	}
+	root := b.wixFile.RegistryRoot()
+	arp := `Software\Microsoft\Windows\CurrentVersion\Uninstall\[ProductName]`
+	values := []struct{ name, typ, value string }{
+		{"AuthorizedCDFPrefix", "string", ""},
+		{"Comments", "string", info.Comments},
+		{"Contact", "string", info.Contact},
+		{"DisplayName", "string", "[ProductName]"},
+		{"DisplayVersion", "string", b.wixFile.Version.Display},
+		{"EstimatedSize", "integer", fmt.Sprint(info.Size)},
+		{"HelpLink", "string", info.HelpLink},
+		{"HelpTelephone", "string", info.SupportTelephone},
+		{"InstallDate", "string", "[Date]"},
+		{"InstallLocation", "string", "[INSTALLDIR]"},
+		{"InstallSource", "string", "[SourceDir]"},
+		{"Language", "integer", "[ProductLanguage]"},
+		{"ModifyPath", "expandable", "MsiExec.exe /I[ProductCode]"},
+		{"Publisher", "string", b.wixFile.Company},
+		{"Readme", "string", info.Readme},
+		{"UninstallString", "expandable", "MsiExec.exe /I[ProductCode]"},
+		{"URLInfoAbout", "string", info.SupportLink},
+		{"URLUpdateInfo", "string", info.UpdateInfoLink},
+		{"Version", "integer", fmt.Sprint(b.wixFile.Version.Hex)},
+	}
+	if b.wixFile.Icon != "" {
+		values = append(values, struct{ name, typ, value string }{
+			"DisplayIcon", "string", `%SystemRoot%\Installer\[ProductCode]\Installer.Ico`})
+	}
+	for _, v := range values {
+		value, err := registryValue(v.typ, v.value)
+		if err != nil {
+			return err
+		}
+		t.Add("RegistryEntriesARP"+v.name, registryRoots[root], arp, v.name, value, "RegistryEntriesARP")
+	}
+	b.component("RegistryEntriesARP", "TARGETDIR", "RegistryEntriesARPDisplayName", "", root+`\`+arp+`\DisplayName`, 0)
+
+	if b.wixFile.Icon != "" {
+		if err := b.icon("Installer.Ico", b.wixFile.Icon); err != nil {
+			return err
+		}
+		b.property("ARPPRODUCTICON", "Installer.Ico")
+	}
+	return nil
+}
+
+func (b *builder) icon(id, path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(b.base, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	b.db.Table("Icon", iconColumns...).Add(id, data)
+	return nil
+}
+
+func (b *builder) shortcuts() error {
+	for _, s := range b.wixFile.Shortcuts {
+		component := "ApplicationShortcuts_" + s.ID
+		id := "ApplicationShortcut_" + s.ID
+		reg := "ShortcutsRegistry_" + s.ID
+		dir := "DesktopFolder"
+		if s.Location == "program" {
+			dir = "ProgramMenuFolder"
+		}
+		var icon interface{}
+		if s.Icon != "" {
+			name := "Icon_" + s.ID + filepath.Ext(s.Icon)
+			if err := b.icon(name, s.Icon); err != nil {
+				return err
+			}
+			icon = name
+		}
+		b.db.Table("Shortcut", shortcutColumns...).Add(id, dir, b.longName(dir, s.Name), component,
+			s.Target, optional(s.Arguments), optional(s.Description), nil, icon, nil, nil, optional(s.WDir))
+		for _, p := range s.Properties {
+			b.db.Table("MsiShortcutProperty", shortcutPropertyColumns...).Add(
+				"ShortcutProperty_"+p.ID, id, p.Key, p.Value)
+		}
+		b.db.Table("Registry", registryColumns...).Add(reg, registryRoots["HKCU"],
+			`Software\[Manufacturer]\[ProductName]`, "shortcut_"+s.ID, "#1", component)
+		b.component(component, "TARGETDIR", reg, s.Condition, `HKCU\Software\[Manufacturer]\[ProductName]\shortcut_`+s.ID, 0)
+	}
+	return nil
+}
+
+// hooks turns the hooks into executable custom actions, run from TARGETDIR.
+func (b *builder) hooks() error {
+	t := b.db.Table("CustomAction", customActionColumns...)
+	seq := b.db.Table("InstallExecuteSequence", sequenceColumns...)
+	before := 0
+	for _, h := range b.wixFile.Hooks {
+		if h.Execute == "immediate" && h.When != "install" {
+			before++
+		}
+	}
+	for i, h := range b.wixFile.Hooks {
+		id := fmt.Sprintf("CustomExec%d", i)
+		typ := caExe
+		switch h.Execute {
+		case "deferred":
+			typ |= caInScript
+			if h.Impersonate == "no" {
+				typ |= caNoImpersonate
+			}
+		case "immediate":
+		default:
+			return fmt.Errorf("unsupported execute %q in hook %q", h.Execute, h.Command)
+		}
+		switch h.Return {
+		case "", "check":
+		case "ignore":
+			typ |= caContinue
+		case "asyncWait":
+			typ |= caAsync
+		case "asyncNoWait":
+			typ |= caAsync | caContinue
+		default:
+			return fmt.Errorf("unsupported return %q in hook %q", h.Return, h.Command)
+		}
+		t.Add(id, typ, "TARGETDIR", manifest.QuoteCommand(h.Command))
+
+		condition := h.Condition
+		switch h.When {
+		case "install":
+			condition = "NOT Installed AND NOT REMOVE"
+			if h.Condition != "" {
+				condition += " AND (" + h.Condition + ")"
+			}
+		case "uninstall":
+			condition = "REMOVE"
+			if h.Condition != "" {
+				condition += " AND (" + h.Condition + ")"
+			}
+		}
+		var sequence int
+		switch {
+		case h.When == "install":
+			sequence = 4001 + i
+		case h.Execute == "immediate":
+			sequence = 1400 - before
+			before--
+		default:
+			sequence = 1501 + i
+		}
+		seq.Add(id, optional(condition), sequence)
+	}
+	return nil
+}
+
+func (b *builder) property(id, value string) {
+	b.db.Table("Property", propertyColumns...).Add(id, value)
+}
+
+func (b *builder) properties() error {
+	w := b.wixFile
+	b.property("ProductCode", guid(uuid.New().String()))
+	b.property("ProductName", w.Product)
+	b.property("ProductVersion", w.Version.MSI)
+	b.property("ProductLanguage", "1033")
+	b.property("Manufacturer", w.Company)
+	b.property("UpgradeCode", guid(w.UpgradeCode))
+	switch w.Scope() {
+	case manifest.PerMachine:
+		b.property("ALLUSERS", "1")
+	case manifest.PerUser:
+		// redirects ProgramFilesFolder to LocalAppData\Programs
+		b.property("ALLUSERS", "2")
+		b.property("MSIINSTALLPERUSER", "1")
+	case manifest.Dual:
+		b.property("ALLUSERS", "2")
+	}
+	b.property("ARPSYSTEMCOMPONENT", "1")
+
+	for _, p := range w.Properties {
+		if p.Value != nil {
+			b.property(p.ID, string(*p.Value))
+		}
+		if p.Registry == nil {
+			if strings.ToUpper(p.ID) == p.ID {
+				b.secure = append(b.secure, p.ID)
+			}
+			continue
+		}
+		root, ok := registryRoots[p.Registry.Root]
+		if !ok || root < 0 {
+			return fmt.Errorf("invalid registry root %q in %q", p.Registry.Root, p.Registry.Path)
+		}
+		typ := locatorRaw
+		if b.win64 {
+			typ |= locator64
+		}
+		signature := p.ID + "Search"
+		b.db.Table("AppSearch", appSearchColumns...).Add(p.ID, signature)
+		b.db.Table("RegLocator", regLocatorColumns...).Add(signature, root, p.Registry.Key, optional(p.Registry.Name), typ)
+	}
+
+	// the passwords of the services are kept out of the logs
+	b.secure = append(b.secure, w.PasswordProperties...)
+	if len(w.PasswordProperties) > 0 {
+		b.property("MsiHiddenProperties", strings.Join(w.PasswordProperties, ";"))
+	}
+
+	for _, c := range w.Conditions {
+		b.db.Table("LaunchCondition", launchConditionColumns...).Add(c.Condition, c.Message)
+	}
+	return nil
+}
+
+// feature mirrors the Feature tree of the WiX templates, the features
+// of the manifest are children of the default feature.
+func (b *builder) feature() error {
+	w := b.wixFile
+	features := b.db.Table("Feature", featureColumns...)
+	t := b.db.Table("FeatureComponents", featureComponentsColumns...)
+	if len(w.Features) == 0 {
+		features.Add(manifest.DefaultFeature, nil, nil, nil, 2, 1, nil, 0)
+	} else {
+		features.Add(manifest.DefaultFeature, nil, w.Product, nil, 1, 1, "INSTALLDIR", featureUIDisallowAbsent)
+	}
+	for _, c := range w.DefaultComponents {
+		t.Add(manifest.DefaultFeature, c)
+	}
+	display := 2
+	var add func(parent string, fs []manifest.Feature)
+	add = func(parent string, fs []manifest.Feature) {
+		for _, f := range fs {
+			features.Add(f.ID, parent, f.Title, optional(f.Description), display, f.Level, nil, 0)
+			display += 2
+			for _, c := range f.Conditions {
+				b.db.Table("Condition", conditionColumns...).Add(f.ID, c.Level, c.Condition)
+			}
+			for _, c := range f.Components {
+				t.Add(f.ID, c)
+			}
+			add(f.ID, f.Features)
+		}
+	}
+	add(manifest.DefaultFeature, w.Features)
+	return nil
+}
+
+// dataDirectories creates the data directories. Their permissions are
+// refused, as the LockPermissions table replaces the inherited permissions
+// where WiX adds to them, and so is their removal, which RemoveFile cannot
+// do for their sub directories.
+func (b *builder) dataDirectories() error {
+	for _, d := range b.wixFile.DataDirectories {
+		if len(d.Permissions) > 0 {
+			return fmt.Errorf("data directory %s: permissions are not supported by the native backend, it would replace the inherited permissions", d.Path)
+		}
+		if d.Uninstall == "remove" || d.Uninstall == "remove-on-purge" {
+			return fmt.Errorf("data directory %s: uninstall %s is not supported by the native backend, it cannot remove the sub directories", d.Path, d.Uninstall)
+		}
+		component := "DataDirectory_" + d.ID
+		reg := "DataDirectoryRegistry_" + d.ID
+		b.component(component, d.Folder, reg, "", d.KeyRoot+`\Software\[Manufacturer]\[ProductName]\datadir_`+d.ID, 0)
+		b.db.Table("Registry", registryColumns...).Add(reg, registryRoots[d.KeyRoot],
+			`Software\[Manufacturer]\[ProductName]`, "datadir_"+d.ID, "["+d.Folder+"]", component)
+		b.db.Table("CreateFolder", createFolderColumns...).Add(d.Folder, component)
+		removeFiles := b.db.Table("RemoveFile", removeFileColumns...)
+		for _, f := range d.RemoveFolders {
+			removeFiles.Add("Remove"+f, component, nil, f, removeOnUninstall)
+		}
+	}
+	return nil
+}
+
+// upgrade mirrors the MajorUpgrade element of the WiX templates.
+func (b *builder) upgrade() error {
+	code := guid(b.wixFile.UpgradeCode)
+	version := b.wixFile.Version.MSI
+	t := b.db.Table("Upgrade", upgradeColumns...)
+	// only detect newer versions
+	t.Add(code, version, nil, nil, 2, nil, "WIX_DOWNGRADE_DETECTED")
+	// migrate features of older versions
+	t.Add(code, nil, version, nil, 1, nil, "WIX_UPGRADE_DETECTED")
+	b.db.Table("LaunchCondition", launchConditionColumns...).Add(
+		"NOT WIX_DOWNGRADE_DETECTED", "A newer version of this software is already installed.")
+	b.secure = append(b.secure, "WIX_DOWNGRADE_DETECTED", "WIX_UPGRADE_DETECTED")
+	b.property("SecureCustomProperties", strings.Join(b.secure, ";"))
+	return nil
+}
+
+func (b *builder) media() error {
+	var buf bytes.Buffer
+	if _, err := b.cab.WriteTo(&buf); err != nil {
+		return err
+	}
+	b.db.Streams[cabinet] = buf.Bytes()
+	b.db.Table("Media", mediaColumns...).Add(1, b.sequence, nil, "#"+cabinet, nil, nil)
+	return nil
+}
+
+// serviceConfigs returns whether the package configures services,
+// which requires Windows Installer 5.
+func (b *builder) serviceConfigs() bool {
+	return len(b.db.Table("MsiServiceConfig", serviceConfigColumns...).Rows) > 0 ||
+		len(b.db.Table("MsiServiceConfigFailureActions", serviceFailureActionsColumns...).Rows) > 0
+}
+
+func (b *builder) sequences() {
+	execute := installExecuteSequence
+	if b.serviceConfigs() {
+		execute = append(execute, action{"MsiConfigureServices", "VersionNT>=600", 5850})
+	}
+	if len(b.db.Table("CreateFolder", createFolderColumns...).Rows) > 0 {
+		execute = append(execute, action{"RemoveFolders", "", 3600}, action{"CreateFolders", "", 3700})
+	}
+	for _, s := range []struct {
+		name    string
+		actions []action
+	}{
+		{"InstallUISequence", installUISequence},
+		{"InstallExecuteSequence", execute},
+		{"AdminUISequence", adminUISequence},
+		{"AdminExecuteSequence", adminExecuteSequence},
+		{"AdvtExecuteSequence", advtExecuteSequence},
+	} {
+		t := b.db.Table(s.name, sequenceColumns...)
+		for _, a := range s.actions {
+			t.Add(a.name, optional(a.condition), a.sequence)
+		}
+	}
+}
+
+func (b *builder) summary() {
+	w := b.wixFile
+	platform := "Intel"
+	if b.win64 {
+		platform = "x64"
+	}
+	pageCount := 200
+	if b.serviceConfigs() {
+		pageCount = 500
+	}
+	wordCount := 2 // compressed, long file names
+	if w.Scope() == manifest.PerUser {
+		wordCount |= 8 // no elevation
+	}
+	now := time.Now()
+	b.db.Summary = msidb.SummaryInfo{
+		Title:      "Installation Database",
+		Subject:    w.Product + " " + w.Version.Display,
+		Author:     w.Company,
+		Keywords:   "Installer",
+		Comments:   "This installs " + w.Product + " " + w.Version.Display,
+		Template:   platform + ";1033",
+		Revision:   guid(uuid.New().String()),
+		CreateTime: now,
+		SaveTime:   now,
+		PageCount:  pageCount,
+		WordCount:  wordCount,
+		AppName:    "go-msi",
+		Security:   2,
+	}
+}
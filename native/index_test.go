@@ -0,0 +1,193 @@
+package native
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stirante/go-msi/cab"
+	"github.com/stirante/go-msi/manifest"
+	"github.com/stirante/go-msi/msidb"
+)
+
+// copyDir copies the files of src to dst.
+func copyDir(t *testing.T, src, dst string) {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rows returns the rows of a table, keyed by their first column.
+func rows(t *testing.T, db *msidb.Database, name string) map[string]map[string]interface{} {
+	for _, table := range db.Tables {
+		if table.Name != name {
+			continue
+		}
+		m := map[string]map[string]interface{}{}
+		for _, row := range table.Rows {
+			r := map[string]interface{}{}
+			for i, c := range table.Columns {
+				r[c.Name] = row[i]
+			}
+			m[fmt.Sprint(row[0])] = r
+		}
+		return m
+	}
+	t.Fatalf("missing table %s", name)
+	return nil
+}
+
+// longName returns the long part of a short|long file name.
+func longName(name string) string {
+	return name[strings.Index(name, "|")+1:]
+}
+
+func TestMakeHello(t *testing.T) {
+	src, err := filepath.Abs(filepath.Join("..", "testing", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "go-msi-native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	copyDir(t, src, dir)
+	exe := []byte("MZ hello")
+	if err := os.MkdirAll(filepath.Join(dir, "build", "amd64"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "build", "amd64", "hello.exe"), exe, 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	wixFile := &manifest.WixManifest{}
+	if err := wixFile.Load("wix.json"); err != nil {
+		t.Fatal(err)
+	}
+	wixFile.Version.User = "1.2.3"
+	if _, err := wixFile.SetGuids(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := wixFile.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := wixFile.RewriteFilePaths(out); err != nil {
+		t.Fatal(err)
+	}
+	msi := filepath.Join(dir, "hello.msi")
+	if err := Make(wixFile, out, "amd64", msi); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(msi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := msidb.Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := rows(t, db, "File")
+	components := rows(t, db, "Component")
+	directories := rows(t, db, "Directory")
+	media := rows(t, db, "Media")
+
+	// the directory of each file, by its long name
+	dirs := map[string]string{}
+	for id, d := range directories {
+		dirs[longName(d["DefaultDir"].(string))] = id
+	}
+	if d := directories[dirs["assets"]]; d == nil || d["Directory_Parent"] != "INSTALLDIR" {
+		t.Errorf("assets directory = %v, want a child of INSTALLDIR", d)
+	}
+	if d := directories[dirs["dir1"]]; d == nil || d["Directory_Parent"] != dirs["assets"] {
+		t.Errorf("dir1 directory = %v, want a child of assets", d)
+	}
+
+	contents := map[string][]byte{"hello.exe": exe}
+	for name, path := range map[string]string{"file1": "some/assets/file1", "file2": "some/assets/dir1/file2"} {
+		if contents[name], err = ioutil.ReadFile(filepath.Join(src, filepath.FromSlash(path))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantDirs := map[string]string{"hello.exe": "INSTALLDIR", "file1": dirs["assets"], "file2": dirs["dir1"]}
+	byName := map[string]string{}
+	sequences := map[int]bool{}
+	for id, f := range files {
+		name := longName(f["FileName"].(string))
+		byName[name] = id
+		c := components[f["Component_"].(string)]
+		if c == nil {
+			t.Errorf("file %s: missing component %s", id, f["Component_"])
+			continue
+		}
+		if c["Directory_"] != wantDirs[name] || c["KeyPath"] != id {
+			t.Errorf("file %s: component %v, want the key path in %s", id, c, wantDirs[name])
+		}
+		if f["FileSize"] != len(contents[name]) {
+			t.Errorf("file %s: size %v, want %d", id, f["FileSize"], len(contents[name]))
+		}
+		sequences[f["Sequence"].(int)] = true
+	}
+	if len(files) != len(contents) || len(byName) != len(contents) {
+		t.Fatalf("files = %v, want %d files", byName, len(contents))
+	}
+	for i := 1; i <= len(files); i++ {
+		if !sequences[i] {
+			t.Errorf("missing file sequence %d", i)
+		}
+	}
+
+	m := media["1"]
+	if len(media) != 1 || m["DiskId"] != 1 || m["LastSequence"] != len(files) || m["Cabinet"] != "#"+cabinet {
+		t.Fatalf("media = %v, want one disk of %d files in #%s", media, len(files), cabinet)
+	}
+	extracted, err := cab.Read(db.Streams[cabinet])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != len(files) {
+		t.Errorf("the cabinet holds %d files, want %d", len(extracted), len(files))
+	}
+	for _, f := range extracted {
+		row := files[f.Name]
+		if row == nil {
+			t.Errorf("cabinet file %s is not in the File table", f.Name)
+			continue
+		}
+		if name := longName(row["FileName"].(string)); !bytes.Equal(f.Data, contents[name]) {
+			t.Errorf("cabinet file %s = %q, want the content of %s", f.Name, f.Data, name)
+		}
+	}
+}
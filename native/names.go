@@ -0,0 +1,74 @@
+package native
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shortChars lists the characters allowed in short (8.3) file names.
+const shortChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_^$~!#%&-{}@`'()"
+
+// longName returns the file name column value of name within dir, made of
+// a short and a long name when name does not fit the 8.3 convention.
+func (b *builder) longName(dir, name string) string {
+	used := b.shortNames[dir]
+	if used == nil {
+		used = make(map[string]bool)
+		b.shortNames[dir] = used
+	}
+	if isShort(name) && !used[strings.ToUpper(name)] {
+		used[strings.ToUpper(name)] = true
+		return name
+	}
+	stem, ext := name, ""
+	if i := strings.LastIndex(name, "."); i > 0 {
+		stem, ext = name[:i], name[i+1:]
+	}
+	stem, ext = shortPart(stem, 6), shortPart(ext, 3)
+	if stem == "" {
+		stem = "FILE"
+	}
+	if ext != "" {
+		ext = "." + ext
+	}
+	for n := 1; ; n++ {
+		suffix := fmt.Sprintf("~%d", n)
+		base := stem
+		if len(base)+len(suffix) > 8 {
+			base = base[:8-len(suffix)]
+		}
+		short := base + suffix + ext
+		if !used[short] {
+			used[short] = true
+			return short + "|" + name
+		}
+	}
+}
+
+func isShort(name string) bool {
+	stem, ext := name, ""
+	if i := strings.Index(name, "."); i >= 0 {
+		stem, ext = name[:i], name[i+1:]
+		if strings.Contains(ext, ".") {
+			return false
+		}
+	}
+	if len(stem) == 0 || len(stem) > 8 || len(ext) > 3 {
+		return false
+	}
+	return shortPart(stem+ext, len(stem)+len(ext)) == strings.ToUpper(stem+ext)
+}
+
+// shortPart keeps up to max valid short name characters of s, upper cased.
+func shortPart(s string, max int) string {
+	var out []byte
+	for _, r := range strings.ToUpper(s) {
+		if len(out) == max {
+			break
+		}
+		if r < 0x80 && strings.IndexByte(shortChars, byte(r)) >= 0 {
+			out = append(out, byte(r))
+		}
+	}
+	return string(out)
+}
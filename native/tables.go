@@ -0,0 +1,209 @@
+package native
+
+import "github.com/stirante/go-msi/msidb"
+
+// Column definitions of the standard installer tables,
+// as documented in the Windows Installer database reference.
+
+func key(name, typ string) msidb.Column {
+	return msidb.Column{Name: name, Type: typ, Key: true}
+}
+
+func col(name, typ string) msidb.Column {
+	return msidb.Column{Name: name, Type: typ}
+}
+
+var propertyColumns = []msidb.Column{
+	key("Property", "s72"), col("Value", "l0"),
+}
+
+var directoryColumns = []msidb.Column{
+	key("Directory", "s72"), col("Directory_Parent", "S72"), col("DefaultDir", "l255"),
+}
+
+var componentColumns = []msidb.Column{
+	key("Component", "s72"), col("ComponentId", "S38"), col("Directory_", "s72"),
+	col("Attributes", "i2"), col("Condition", "S255"), col("KeyPath", "S72"),
+}
+
+var featureColumns = []msidb.Column{
+	key("Feature", "s38"), col("Feature_Parent", "S38"), col("Title", "L64"), col("Description", "L255"),
+	col("Display", "I2"), col("Level", "i2"), col("Directory_", "S72"), col("Attributes", "i2"),
+}
+
+var featureComponentsColumns = []msidb.Column{
+	key("Feature_", "s38"), key("Component_", "s72"),
+}
+
+var conditionColumns = []msidb.Column{
+	key("Feature_", "s38"), key("Level", "i2"), col("Condition", "S255"),
+}
+
+var createFolderColumns = []msidb.Column{
+	key("Directory_", "s72"), key("Component_", "s72"),
+}
+
+var removeFileColumns = []msidb.Column{
+	key("FileKey", "s72"), col("Component_", "s72"), col("FileName", "L255"), col("DirProperty", "s72"), col("InstallMode", "i2"),
+}
+
+var fileColumns = []msidb.Column{
+	key("File", "s72"), col("Component_", "s72"), col("FileName", "l255"), col("FileSize", "i4"),
+	col("Version", "S72"), col("Language", "S20"), col("Attributes", "I2"), col("Sequence", "i4"),
+}
+
+var mediaColumns = []msidb.Column{
+	key("DiskId", "i2"), col("LastSequence", "i4"), col("DiskPrompt", "L64"),
+	col("Cabinet", "S255"), col("VolumeLabel", "S32"), col("Source", "S72"),
+}
+
+var registryColumns = []msidb.Column{
+	key("Registry", "s72"), col("Root", "i2"), col("Key", "l255"), col("Name", "L255"),
+	col("Value", "L0"), col("Component_", "s72"),
+}
+
+var shortcutColumns = []msidb.Column{
+	key("Shortcut", "s72"), col("Directory_", "s72"), col("Name", "l128"), col("Component_", "s72"),
+	col("Target", "s72"), col("Arguments", "S255"), col("Description", "L255"), col("Hotkey", "I2"),
+	col("Icon_", "S72"), col("IconIndex", "I2"), col("ShowCmd", "I2"), col("WkDir", "S72"),
+}
+
+var shortcutPropertyColumns = []msidb.Column{
+	key("MsiShortcutProperty", "s72"), col("Shortcut_", "s72"),
+	col("PropertyKey", "s0"), col("PropVariantValue", "s0"),
+}
+
+var iconColumns = []msidb.Column{
+	key("Name", "s72"), col("Data", "v0"),
+}
+
+var serviceInstallColumns = []msidb.Column{
+	key("ServiceInstall", "s72"), col("Name", "s255"), col("DisplayName", "L255"),
+	col("ServiceType", "i4"), col("StartType", "i4"), col("ErrorControl", "i4"),
+	col("LoadOrderGroup", "S255"), col("Dependencies", "S255"), col("StartName", "S255"),
+	col("Password", "S255"), col("Arguments", "S255"), col("Component_", "s72"), col("Description", "L255"),
+}
+
+var serviceControlColumns = []msidb.Column{
+	key("ServiceControl", "s72"), col("Name", "l255"), col("Event", "i2"),
+	col("Arguments", "S255"), col("Wait", "I2"), col("Component_", "s72"),
+}
+
+var serviceConfigColumns = []msidb.Column{
+	key("MsiServiceConfig", "s72"), col("Name", "s255"), col("Event", "i2"),
+	col("ConfigType", "i4"), col("Argument", "S0"), col("Component_", "s72"),
+}
+
+var serviceFailureActionsColumns = []msidb.Column{
+	key("MsiServiceConfigFailureActions", "s72"), col("Name", "s255"), col("Event", "i2"),
+	col("ResetPeriod", "I4"), col("RebootMessage", "L255"), col("Command", "L255"),
+	col("Actions", "S255"), col("DelayActions", "S255"), col("Component_", "s72"),
+}
+
+var environmentColumns = []msidb.Column{
+	key("Environment", "s72"), col("Name", "l255"), col("Value", "L255"), col("Component_", "s72"),
+}
+
+var launchConditionColumns = []msidb.Column{
+	key("Condition", "s255"), col("Description", "l255"),
+}
+
+var appSearchColumns = []msidb.Column{
+	key("Property", "s72"), key("Signature_", "s72"),
+}
+
+var regLocatorColumns = []msidb.Column{
+	key("Signature_", "s72"), col("Root", "i2"), col("Key", "s255"), col("Name", "S255"), col("Type", "I2"),
+}
+
+var upgradeColumns = []msidb.Column{
+	key("UpgradeCode", "s38"), key("VersionMin", "S20"), key("VersionMax", "S20"), key("Language", "S255"),
+	key("Attributes", "i4"), col("Remove", "S255"), col("ActionProperty", "s72"),
+}
+
+var customActionColumns = []msidb.Column{
+	key("Action", "s72"), col("Type", "i2"), col("Source", "S72"), col("Target", "S255"),
+}
+
+var sequenceColumns = []msidb.Column{
+	key("Action", "s72"), col("Condition", "S255"), col("Sequence", "I2"),
+}
+
+type action struct {
+	name      string
+	condition string
+	sequence  int
+}
+
+// Standard actions, scheduled as WiX does by default.
+var (
+	installUISequence = []action{
+		{"FindRelatedProducts", "", 25},
+		{"AppSearch", "", 50},
+		{"LaunchConditions", "", 100},
+		{"ValidateProductID", "", 700},
+		{"CostInitialize", "", 800},
+		{"FileCost", "", 900},
+		{"CostFinalize", "", 1000},
+		{"MigrateFeatureStates", "", 1200},
+		{"ExecuteAction", "", 1300},
+	}
+	installExecuteSequence = []action{
+		{"FindRelatedProducts", "", 25},
+		{"AppSearch", "", 50},
+		{"LaunchConditions", "", 100},
+		{"ValidateProductID", "", 700},
+		{"CostInitialize", "", 800},
+		{"FileCost", "", 900},
+		{"CostFinalize", "", 1000},
+		{"MigrateFeatureStates", "", 1200},
+		{"InstallValidate", "", 1400},
+		{"RemoveExistingProducts", "", 1401},
+		{"InstallInitialize", "", 1500},
+		{"ProcessComponents", "", 1600},
+		{"UnpublishFeatures", "", 1800},
+		{"StopServices", "VersionNT", 1900},
+		{"DeleteServices", "VersionNT", 2000},
+		{"RemoveRegistryValues", "", 2600},
+		{"RemoveShortcuts", "", 3200},
+		{"RemoveEnvironmentStrings", "", 3300},
+		{"RemoveFiles", "", 3500},
+		{"InstallFiles", "", 4000},
+		{"CreateShortcuts", "", 4500},
+		{"WriteRegistryValues", "", 5000},
+		{"WriteEnvironmentStrings", "", 5200},
+		{"InstallServices", "VersionNT", 5800},
+		{"StartServices", "VersionNT", 5900},
+		{"RegisterUser", "", 6000},
+		{"RegisterProduct", "", 6100},
+		{"PublishFeatures", "", 6300},
+		{"PublishProduct", "", 6400},
+		{"InstallFinalize", "", 6600},
+	}
+	adminUISequence = []action{
+		{"CostInitialize", "", 800},
+		{"FileCost", "", 900},
+		{"CostFinalize", "", 1000},
+		{"ExecuteAction", "", 1300},
+	}
+	adminExecuteSequence = []action{
+		{"CostInitialize", "", 800},
+		{"FileCost", "", 900},
+		{"CostFinalize", "", 1000},
+		{"InstallValidate", "", 1400},
+		{"InstallInitialize", "", 1500},
+		{"InstallAdminPackage", "", 3900},
+		{"InstallFiles", "", 4000},
+		{"InstallFinalize", "", 6600},
+	}
+	advtExecuteSequence = []action{
+		{"CostInitialize", "", 800},
+		{"CostFinalize", "", 1000},
+		{"InstallValidate", "", 1400},
+		{"InstallInitialize", "", 1500},
+		{"CreateShortcuts", "", 4500},
+		{"PublishFeatures", "", 6300},
+		{"PublishProduct", "", 6400},
+		{"InstallFinalize", "", 6600},
+	}
+)
@@ -6,7 +6,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -50,21 +49,22 @@ func Find(srcDir string, pattern string) ([]string, error) {
 	return doublestar.Glob(glob)
 }
 
-// GenerateTemplate generates given src template to out file using given manifest
-func GenerateTemplate(wixFile *manifest.WixManifest, src string, out string) error {
-	tpl, err := template.New("").Funcs(funcMap).ParseFiles(src)
+// GenerateTemplate generates given src template to out file using given manifest,
+// it returns the source map of the generated file.
+func GenerateTemplate(wixFile *manifest.WixManifest, src string, out string) (*SourceMap, error) {
+	tpl, err := template.New("").Funcs(funcMap).Funcs(originFuncs(wixFile)).ParseFiles(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fileWriter, err := os.Create(out)
+	var b bytes.Buffer
+	err = tpl.ExecuteTemplate(&b, filepath.Base(src), wixFile)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer fileWriter.Close()
-	err = tpl.ExecuteTemplate(fileWriter, filepath.Base(src), wixFile)
-	if err != nil {
-		return err
+	content, sourceMap := newSourceMap(b.String(), wixFile.Fields())
+	if err := ioutil.WriteFile(out, []byte(content), 0644); err != nil {
+		return nil, err
 	}
-	return nil
+	return sourceMap, nil
 }
@@ -0,0 +1,192 @@
+package templates
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/stirante/go-msi/manifest"
+)
+
+// SourceMap relates the lines of a generated template to the manifest
+// items rendered on them, as recorded by the origin template function.
+type SourceMap struct {
+	lines  []string
+	items  map[int][]string // paths of the items rendered on each line, if known
+	fields []manifest.Field
+}
+
+// origin is a manifest field rendered on a line,
+// within the given xml attribute if any.
+type origin struct {
+	field     string
+	attribute string
+	exact     bool // the attribute value is the field value
+}
+
+var (
+	attribute = regexp.MustCompile(`([\w:]+)\s*=\s*"([^"]*)"`)
+	// marker opens the scope of an item with its path, or closes it
+	marker = regexp.MustCompile("\x00([<>])([^\x00]*)\x00")
+)
+
+// minimum length of a value matched within a longer text.
+const minPartialMatch = 4
+
+// originFuncs returns the template functions recording the items of
+// wixFile rendered by a template: {{origin $item}} opens the scope of an
+// item, such as a file or a shortcut, and {{endorigin}} closes it.
+func originFuncs(wixFile *manifest.WixManifest) template.FuncMap {
+	items := wixFile.Items()
+	return template.FuncMap{
+		"origin": func(v reflect.Value) string {
+			path := ""
+			if item, ok := manifest.ItemOf(v); ok {
+				path = items[item]
+			}
+			return "\x00>" + path + "\x00"
+		},
+		"endorigin": func() string {
+			return "\x00<\x00"
+		},
+	}
+}
+
+// newSourceMap removes the markers of the origin functions from content,
+// it returns the content and the items rendered on each of its lines: the
+// items opened on the line, or else the innermost item the line is in. The
+// lines of the items missing from the manifest are left out.
+func newSourceMap(content string, fields []manifest.Field) (string, *SourceMap) {
+	m := &SourceMap{
+		lines:  strings.Split(content, "\n"),
+		items:  make(map[int][]string),
+		fields: fields,
+	}
+	var scopes []string
+	for i, line := range m.lines {
+		var inherited []string
+		if n := len(scopes); n > 0 {
+			inherited = []string{scopes[n-1]}
+		}
+		var opened []string
+		for _, mk := range marker.FindAllStringSubmatch(line, -1) {
+			if mk[1] == ">" {
+				scopes = append(scopes, mk[2])
+				if mk[2] != "" {
+					opened = append(opened, mk[2])
+				}
+			} else if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+		m.lines[i] = marker.ReplaceAllString(line, "")
+		if opened == nil {
+			opened = inherited
+		}
+		if len(opened) != 1 || opened[0] != "" {
+			m.items[i+1] = opened
+		}
+	}
+	return strings.Join(m.lines, "\n"), m
+}
+
+// Lookup returns the manifest fields rendered into the element starting at
+// the given line, among the fields of its items, or of the top level of the
+// manifest outside of any item. It prefers the fields rendered into the
+// attribute named by message, as in Shortcut/@Target, the fields rendered
+// as a whole attribute value and into an attribute of the same name. It
+// returns the items themselves when none of their fields is rendered there.
+func (m *SourceMap) Lookup(line int, message string) []string {
+	if m == nil || line < 1 || line > len(m.lines) {
+		return nil
+	}
+	// the element ends at the next element, its character data included
+	text := m.lines[line-1]
+	for l := line + 1; l <= len(m.lines); l++ {
+		trimmed := strings.TrimSpace(m.lines[l-1])
+		if strings.HasPrefix(trimmed, "<") && !strings.HasPrefix(trimmed, "<![CDATA[") {
+			break
+		}
+		text += "\n" + m.lines[l-1]
+	}
+	attrs := attribute.FindAllStringSubmatch(text, -1)
+
+	items, ok := m.items[line]
+	if !ok {
+		return nil
+	}
+	var origins []origin
+	for _, f := range m.fields {
+		if !ownField(items, f.Path) || !strings.Contains(text, f.Value) {
+			continue
+		}
+		var found []origin
+		for _, a := range attrs {
+			if strings.Contains(a[2], f.Value) {
+				found = append(found, origin{field: f.Path, attribute: a[1], exact: a[2] == f.Value})
+			}
+		}
+		if len(found) == 0 {
+			found = append(found, origin{field: f.Path})
+		}
+		for _, o := range found {
+			if o.exact || len(f.Value) >= minPartialMatch {
+				origins = append(origins, o)
+			}
+		}
+	}
+	if len(origins) == 0 {
+		return items
+	}
+
+	if i := strings.Index(message, "/@"); i > -1 {
+		name := message[i+2:]
+		if j := strings.IndexFunc(name, func(r rune) bool { return r == ' ' || r == '\'' || r == '"' }); j > -1 {
+			name = name[:j]
+		}
+		origins = prefer(origins, func(o origin) bool { return o.attribute == name })
+	}
+	origins = prefer(origins, func(o origin) bool { return o.exact })
+	origins = prefer(origins, func(o origin) bool {
+		name := o.field[strings.LastIndexAny(o.field, ".]")+1:]
+		return strings.EqualFold(strings.Replace(name, "-", "", -1), o.attribute)
+	})
+	var fields []string
+	seen := make(map[string]bool)
+	for _, o := range origins {
+		if !seen[o.field] {
+			seen[o.field] = true
+			fields = append(fields, o.field)
+		}
+	}
+	return fields
+}
+
+// ownField returns whether path is a field of one of the items, not of their
+// sub items, or a top level field when there are no items.
+func ownField(items []string, path string) bool {
+	if len(items) == 0 {
+		return !strings.Contains(path, "[")
+	}
+	for _, item := range items {
+		if strings.HasPrefix(path, item+".") && !strings.Contains(path[len(item):], "[") {
+			return true
+		}
+	}
+	return false
+}
+
+// prefer returns the origins satisfying f, or all of them if none does.
+func prefer(origins []origin, f func(origin) bool) []origin {
+	var preferred []origin
+	for _, o := range origins {
+		if f(o) {
+			preferred = append(preferred, o)
+		}
+	}
+	if len(preferred) == 0 {
+		return origins
+	}
+	return preferred
+}
@@ -0,0 +1,91 @@
+package templates
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stirante/go-msi/manifest"
+)
+
+func TestSourceMap(t *testing.T) {
+	wixFile := &manifest.WixManifest{
+		Product: "hello",
+		Company: "mh-cbon",
+		Info:    &manifest.Info{},
+		Environments: []manifest.Environment{
+			{ID: "0", Name: "HELLO_HOME", Value: "[INSTALLDIR]", Permanent: "no", System: "yes", Action: "set", Part: "all"},
+			{ID: "1", Name: "HELLO_PATH", Value: "[INSTALLDIR]bin", Permanent: "no", System: "yes", Action: "set", Part: "last", Condition: "PATHCONDITION"},
+		},
+		Shortcuts: []manifest.Shortcut{
+			{ID: "0", Name: "hello", Description: "Say hello", Location: "program", Target: "[INSTALLDIR]hello.exe", WDir: "INSTALLDIR"},
+			{ID: "1", Name: "hello desktop", Description: "Say hello from the desktop", Location: "desktop", Target: "[INSTALLDIR]hi.exe", WDir: "INSTALLDIR",
+				Properties: []manifest.ShortcutProperty{{ID: "0", Key: "System.AppUserModel.ID", Value: "hello.desktop"}}},
+		},
+		Hooks: []manifest.Hook{
+			{Command: "cmd /c echo first", CookedCommand: "cmd /c echo first", When: "install", Execute: "deferred", Impersonate: "no"},
+			{Command: "cmd /c echo second", CookedCommand: "cmd /c echo second", When: "install", Execute: "deferred", Impersonate: "no", Condition: "HOOKCONDITION"},
+		},
+	}
+	dir, err := ioutil.TempDir("", "go-msi-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "product.wxs")
+	m, err := GenerateTemplate(wixFile, "product.wxs", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "\x00") {
+		t.Fatal("the origin markers are left in the generated file")
+	}
+	lines := strings.Split(string(b), "\n")
+	// find returns the line of the nth element starting with prefix.
+	find := func(prefix string, nth int) int {
+		for i, l := range lines {
+			if strings.HasPrefix(strings.TrimSpace(l), prefix) {
+				if nth == 0 {
+					return i + 1
+				}
+				nth--
+			}
+		}
+		t.Fatalf("missing %s element %d in\n%s", prefix, nth, b)
+		return 0
+	}
+
+	for _, c := range []struct {
+		element string
+		nth     int
+		message string
+		fields  []string
+	}{
+		{"<Product ", 0, "The Product/@Manufacturer attribute's value is invalid.", []string{"company"}},
+		{"<Component Id=\"Environments_", 1, "", []string{"environments[1]"}},
+		{"<Environment ", 0, "The Environment/@Name attribute's value is invalid.", []string{"environments[0].name"}},
+		{"<Environment ", 1, "The Environment/@Value attribute's value is invalid.", []string{"environments[1].value"}},
+		{"<Environment ", 1, "", []string{"environments[1].name", "environments[1].value", "environments[1].permanent", "environments[1].system", "environments[1].action", "environments[1].part"}},
+		{"<Condition>", 0, "", []string{"environments[1].condition"}},
+		{"<Shortcut ", 0, "The Shortcut/@Target attribute's value is invalid.", []string{"shortcuts[0].target"}},
+		{"<Shortcut ", 1, "The Shortcut/@Target attribute's value is invalid.", []string{"shortcuts[1].target"}},
+		{"<Shortcut ", 1, "The Shortcut/@Description attribute's value is invalid.", []string{"shortcuts[1].description"}},
+		{"<ShortcutProperty ", 0, "", []string{"shortcuts[1].properties[0].key", "shortcuts[1].properties[0].value"}},
+		{"<SetProperty ", 1, "", []string{"hooks[1].command"}},
+		{"<CustomAction ", 1, "The CustomAction/@Execute attribute's value is invalid.", []string{"hooks[1].execute"}},
+		{"<Custom ", 0, "", []string{"hooks[0]"}},
+		{"<Custom ", 1, "", []string{"hooks[1].condition"}},
+	} {
+		line := find(c.element, c.nth)
+		if got := m.Lookup(line, c.message); !reflect.DeepEqual(got, c.fields) {
+			t.Errorf("%s %d: fields = %q, want %q", c.element, c.nth, got, c.fields)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package wix
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Diagnostic is an error or a warning reported by a toolchain command.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`       // error or warning
+	Code     string `json:"code,omitempty"` // for example CNDL0001, empty for wixl
+	Message  string `json:"message"`
+}
+
+var (
+	wixDiagnostic  = regexp.MustCompile(`^\s*(.+?)\((\d+)(?:,\d+)?\)\s*:\s*(error|warning)\s+([A-Z]+\d+)\s*:\s*(.*)$`)
+	wixlDiagnostic = regexp.MustCompile(`^\s*(.+?\.wxs):(\d+)(?::\d+)?:\s*(error|warning):\s*(.*)$`)
+)
+
+// ParseDiagnostic parses a diagnostic line of candle, light or wixl,
+// such as product.wxs(12) : error CNDL0001 : message.
+func ParseDiagnostic(line string) (Diagnostic, bool) {
+	if m := wixDiagnostic.FindStringSubmatch(line); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		return Diagnostic{File: m[1], Line: n, Severity: m[3], Code: m[4], Message: m[5]}, true
+	}
+	if m := wixlDiagnostic.FindStringSubmatch(line); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		return Diagnostic{File: m[1], Line: n, Severity: m[3], Message: m[4]}, true
+	}
+	return Diagnostic{}, false
+}
+
+// LineWriter passes every line written to it through a function
+// before writing it to the underlying writer.
+type LineWriter struct {
+	w    io.Writer
+	f    func(line string) string
+	rest []byte
+}
+
+// NewLineWriter returns a LineWriter writing the lines transformed by f to w.
+func NewLineWriter(w io.Writer, f func(line string) string) *LineWriter {
+	return &LineWriter{w: w, f: f}
+}
+
+func (l *LineWriter) Write(p []byte) (int, error) {
+	l.rest = append(l.rest, p...)
+	for {
+		i := bytes.IndexByte(l.rest, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := bytes.TrimRight(l.rest[:i], "\r")
+		if _, err := io.WriteString(l.w, l.f(string(line))+"\n"); err != nil {
+			return len(p), err
+		}
+		l.rest = l.rest[i+1:]
+	}
+}
+
+// Flush writes the last line when it is not terminated by a new line.
+func (l *LineWriter) Flush() error {
+	if len(l.rest) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(l.w, l.f(string(l.rest))+"\n")
+	l.rest = nil
+	return err
+}
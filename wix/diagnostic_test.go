@@ -0,0 +1,31 @@
+package wix
+
+import "testing"
+
+func TestParseDiagnostic(t *testing.T) {
+	for _, c := range []struct {
+		line string
+		want Diagnostic
+		ok   bool
+	}{
+		{
+			`product.wxs(12) : error CNDL0001 : unexpected attribute`,
+			Diagnostic{File: "product.wxs", Line: 12, Severity: "error", Code: "CNDL0001", Message: "unexpected attribute"}, true,
+		},
+		{
+			`C:\build\my product.wxs(7,5) : warning LGHT1076 : ICE61: upgrade`,
+			Diagnostic{File: `C:\build\my product.wxs`, Line: 7, Severity: "warning", Code: "LGHT1076", Message: "ICE61: upgrade"}, true,
+		},
+		{
+			`product.wxs:42: error: unhandled child Foo`,
+			Diagnostic{File: "product.wxs", Line: 42, Severity: "error", Message: "unhandled child Foo"}, true,
+		},
+		{`Windows Installer XML Toolset Compiler version 3.11`, Diagnostic{}, false},
+		{``, Diagnostic{}, false},
+	} {
+		got, ok := ParseDiagnostic(c.line)
+		if ok != c.ok || got != c.want {
+			t.Errorf("ParseDiagnostic(%q) = %+v, %v, want %+v, %v", c.line, got, ok, c.want, c.ok)
+		}
+	}
+}
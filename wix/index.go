@@ -1,6 +1,7 @@
 package wix
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -9,30 +10,92 @@ import (
 
 var eol = "\r\n"
 
-// GenerateCmd generates required command lines to produce an msi package,
-func GenerateCmd(wixFile *manifest.WixManifest, templates []string, msiOutFile, arch, path string) string {
+// Toolchain compiles wix sources into an msi package.
+type Toolchain interface {
+	// Name returns the name of the toolchain, as given to Find.
+	Name() string
+	// Script returns the file name of the generated build script.
+	Script() string
+	// Prepare adapts the generated templates to the toolchain,
+	// it returns the templates to compile and a note for every unsupported
+	// construct it dropped, or an error for those it must not drop.
+	Prepare(templates []string) ([]string, []string, error)
+	// Commands returns the command lines compiling the templates,
+	// path is the directory of the toolchain binaries, if not in PATH.
+	Commands(templates []string, msiOutFile, arch, path string) [][]string
+	// GenerateCmd generates the content of the build script.
+	GenerateCmd(templates []string, msiOutFile, arch, path string) string
+	// Command returns the command line running the build script.
+	Command() []string
+}
 
-	cmd := ""
+// Toolchains lists the supported toolchains.
+var Toolchains = []Toolchain{WiX{}, Wixl{}}
 
-	cmd += filepath.Join(path, "candle")
-	if arch != "" {
-		if arch == "386" {
-			arch = "x86"
-		} else if arch == "amd64" {
-			arch = "x64"
+// Find returns the toolchain with the given name.
+func Find(name string) (Toolchain, error) {
+	var names []string
+	for _, t := range Toolchains {
+		if t.Name() == name {
+			return t, nil
 		}
-		cmd += " -arch " + arch
+		names = append(names, t.Name())
 	}
-	for _, tpl := range templates {
-		cmd += " " + filepath.Base(tpl)
+	return nil, fmt.Errorf("invalid toolchain %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// Arch returns the wix name of a go architecture.
+func Arch(arch string) string {
+	if arch == "386" {
+		return "x86"
+	} else if arch == "amd64" {
+		return "x64"
 	}
-	cmd += eol
-	cmd += filepath.Join(path, "light") + " -ext WixUIExtension -ext WixUtilExtension -sacl -spdb "
-	cmd += " -out " + msiOutFile
+	return arch
+}
+
+// WiX is the WiX toolset toolchain, candle and light.
+type WiX struct{}
+
+// Name returns wix.
+func (WiX) Name() string { return "wix" }
+
+// Script returns build.bat.
+func (WiX) Script() string { return "build.bat" }
+
+// Prepare returns the templates unchanged.
+func (WiX) Prepare(templates []string) ([]string, []string, error) {
+	return templates, nil, nil
+}
+
+// Command runs the script with cmd.exe.
+func (t WiX) Command() []string { return []string{"cmd.exe", "/C", t.Script()} }
+
+// Commands returns the candle and light command lines.
+func (WiX) Commands(templates []string, msiOutFile, arch, path string) [][]string {
+	candle := []string{filepath.Join(path, "candle"), "-ext", "WixUtilExtension"}
+	if arch != "" {
+		candle = append(candle, "-arch", Arch(arch))
+	}
+	light := []string{filepath.Join(path, "light"), "-ext", "WixUIExtension", "-ext", "WixUtilExtension", "-sacl", "-spdb", "-out", msiOutFile}
 	for _, tpl := range templates {
-		cmd += " " + strings.Replace(filepath.Base(tpl), ".wxs", ".wixobj", -1)
+		candle = append(candle, filepath.Base(tpl))
+		light = append(light, strings.Replace(filepath.Base(tpl), ".wxs", ".wixobj", -1))
 	}
-	cmd += eol
+	return [][]string{candle, light}
+}
 
+// GenerateCmd generates a batch file of the candle and light command lines.
+func (t WiX) GenerateCmd(templates []string, msiOutFile, arch, path string) string {
+	cmd := ""
+	for _, args := range t.Commands(templates, msiOutFile, arch, path) {
+		cmd += FormatCommand(args, QuoteBat) + eol
+	}
 	return cmd
 }
+
+// GenerateCmd generates required command lines to produce an msi package,
+// using the WiX toolset.
+func GenerateCmd(wixFile *manifest.WixManifest, templates []string, msiOutFile, arch, path string) string {
+	return WiX{}.GenerateCmd(templates, msiOutFile, arch, path)
+}
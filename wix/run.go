@@ -0,0 +1,93 @@
+package wix
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Runner runs toolchain commands, without going through a shell.
+type Runner struct {
+	Dir    string // working directory of the commands
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// CommandError reports a command which did not complete successfully.
+type CommandError struct {
+	Args     []string
+	ExitCode int // -1 when the command did not exit on its own
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	name := filepath.Base(e.Args[0])
+	if e.ExitCode >= 0 {
+		return fmt.Sprintf("%s failed with exit code %d", name, e.ExitCode)
+	}
+	return fmt.Sprintf("%s failed: %v", name, e.Err)
+}
+
+// Run runs the commands in order, the output of the commands is streamed
+// to the runner writers. It stops at the first failing command, and kills
+// the running command when ctx is done.
+func (r *Runner) Run(ctx context.Context, commands [][]string) error {
+	stdout, stderr := r.Stdout, r.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	for _, args := range commands {
+		fmt.Fprintln(stdout, FormatCommand(args, QuoteSh))
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = r.Dir
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return &CommandError{Args: args, ExitCode: -1, Err: ctx.Err()}
+			}
+			if e, ok := err.(*exec.ExitError); ok {
+				return &CommandError{Args: args, ExitCode: e.ExitCode(), Err: err}
+			}
+			return &CommandError{Args: args, ExitCode: -1, Err: err}
+		}
+	}
+	return nil
+}
+
+// FormatCommand joins the arguments into a command line,
+// each argument is quoted with quote.
+func FormatCommand(args []string, quote func(string) string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=+,@%-]+$`)
+
+// QuoteSh quotes an argument for a POSIX shell.
+func QuoteSh(arg string) string {
+	if shSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// QuoteBat quotes an argument for a batch file.
+func QuoteBat(arg string) string {
+	arg = strings.Replace(arg, "%", "%%", -1)
+	if arg != "" && !strings.ContainsAny(arg, " \t&|<>^(),;=\"") {
+		return arg
+	}
+	return `"` + strings.Replace(arg, `"`, `\"`, -1) + `"`
+}
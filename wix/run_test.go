@@ -0,0 +1,143 @@
+package wix
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTools writes candle and light shell scripts running script in a
+// directory whose path has a space, and returns the directory.
+func fakeTools(t *testing.T, candle, light string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake toolchain is made of shell scripts")
+	}
+	dir, err := ioutil.TempDir("", "go-msi-wix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "wix tools")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, script := range map[string]string{"candle": candle, "light": light} {
+		if err := ioutil.WriteFile(filepath.Join(path, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// recordArgs writes the arguments of the script, one per line, to a file named after it.
+const recordArgs = `for a in "$@"; do echo "$a"; done > "$(basename "$0").args"`
+
+func TestRunArgs(t *testing.T) {
+	path := fakeTools(t, recordArgs, recordArgs)
+	work := filepath.Dir(path)
+	templates := []string{filepath.Join(work, "my product.wxs"), filepath.Join(work, "WixUI_HK.wxs")}
+	commands := WiX{}.Commands(templates, "out dir/my app.msi", "amd64", path)
+
+	var stdout bytes.Buffer
+	r := &Runner{Dir: work, Stdout: &stdout}
+	if err := r.Run(context.Background(), commands); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		tool string
+		args []string
+	}{
+		{"candle", []string{"-ext", "WixUtilExtension", "-arch", "x64", "my product.wxs", "WixUI_HK.wxs"}},
+		{"light", []string{"-ext", "WixUIExtension", "-ext", "WixUtilExtension", "-sacl", "-spdb", "-out", "out dir/my app.msi", "my product.wixobj", "WixUI_HK.wixobj"}},
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(work, want.tool+".args"))
+		if err != nil {
+			t.Fatalf("%s was not run: %v", want.tool, err)
+		}
+		args := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if !reflect.DeepEqual(args, want.args) {
+			t.Errorf("%s args = %q, want %q", want.tool, args, want.args)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	wantLines := []string{
+		QuoteSh(filepath.Join(path, "candle")) + " -ext WixUtilExtension -arch x64 'my product.wxs' WixUI_HK.wxs",
+		QuoteSh(filepath.Join(path, "light")) + " -ext WixUIExtension -ext WixUtilExtension -sacl -spdb -out 'out dir/my app.msi' 'my product.wixobj' WixUI_HK.wixobj",
+	}
+	if !reflect.DeepEqual(lines, wantLines) {
+		t.Errorf("printed commands = %q, want %q", lines, wantLines)
+	}
+	if !strings.HasPrefix(lines[0], "'") {
+		t.Errorf("the tool path with a space is not quoted: %s", lines[0])
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	path := fakeTools(t, "exit 0", "echo 'light failed' >&2; exit 3")
+	var stderr bytes.Buffer
+	r := &Runner{Dir: filepath.Dir(path), Stderr: &stderr}
+	err := r.Run(context.Background(), WiX{}.Commands([]string{"product.wxs"}, "out.msi", "", path))
+	e, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("error = %v, want a *CommandError", err)
+	}
+	if e.ExitCode != 3 || filepath.Base(e.Args[0]) != "light" {
+		t.Errorf("error = %+v, want light failing with exit code 3", e)
+	}
+	if want := "light failed with exit code 3"; err.Error() != want {
+		t.Errorf("message = %q, want %q", err.Error(), want)
+	}
+	if !strings.Contains(stderr.String(), "light failed") {
+		t.Errorf("stderr = %q, want the output of light", stderr.String())
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	path := fakeTools(t, "exec sleep 30", recordArgs)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r := &Runner{Dir: filepath.Dir(path)}
+	start := time.Now()
+	err := r.Run(ctx, WiX{}.Commands([]string{"product.wxs"}, "out.msi", "", path))
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("candle was not killed, Run returned after %s", elapsed)
+	}
+	e, ok := err.(*CommandError)
+	if !ok || e.ExitCode != -1 || e.Err != context.DeadlineExceeded {
+		t.Fatalf("error = %#v, want a *CommandError of the deadline", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "light.args")); err == nil {
+		t.Error("light ran after candle was killed")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, c := range []struct {
+		arg, sh, bat string
+	}{
+		{"product.wxs", "product.wxs", "product.wxs"},
+		{"C:/wix/bin/candle", "C:/wix/bin/candle", "C:/wix/bin/candle"},
+		{"my product.wxs", "'my product.wxs'", `"my product.wxs"`},
+		{"it's", `'it'\''s'`, "it's"},
+		{`say "hi"`, `'say "hi"'`, `"say \"hi\""`},
+		{"100%", "100%", "100%%"},
+		{"a&b", "'a&b'", `"a&b"`},
+		{"", "''", `""`},
+	} {
+		if got := QuoteSh(c.arg); got != c.sh {
+			t.Errorf("QuoteSh(%q) = %s, want %s", c.arg, got, c.sh)
+		}
+		if got := QuoteBat(c.arg); got != c.bat {
+			t.Errorf("QuoteBat(%q) = %s, want %s", c.arg, got, c.bat)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package wix
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Wixl is the msitools toolchain, it runs on Linux but does not support
+// the WiX extensions. The installer UI is dropped from the templates, the
+// util elements and custom actions fail the build unless Drop is set.
+type Wixl struct {
+	Drop bool // drop the util elements and custom actions instead of failing
+}
+
+// Name returns wixl.
+func (Wixl) Name() string { return "wixl" }
+
+// Script returns build.sh.
+func (Wixl) Script() string { return "build.sh" }
+
+// Command runs the script with sh.
+func (t Wixl) Command() []string { return []string{"sh", t.Script()} }
+
+// Commands returns the wixl command line.
+func (Wixl) Commands(templates []string, msiOutFile, arch, path string) [][]string {
+	wixl := []string{filepath.Join(path, "wixl")}
+	if arch != "" {
+		wixl = append(wixl, "-a", Arch(arch))
+	}
+	wixl = append(wixl, "-o", filepath.ToSlash(msiOutFile))
+	for _, tpl := range templates {
+		wixl = append(wixl, filepath.Base(tpl))
+	}
+	return [][]string{wixl}
+}
+
+// GenerateCmd generates a shell script of the wixl command line.
+func (t Wixl) GenerateCmd(templates []string, msiOutFile, arch, path string) string {
+	cmd := "#!/bin/sh\nset -e\n"
+	for _, args := range t.Commands(templates, msiOutFile, arch, path) {
+		cmd += FormatCommand(args, QuoteSh) + "\n"
+	}
+	return cmd
+}
+
+// unsupported lists the extension usages wixl cannot compile, the
+// essential ones change the installed system and are only dropped with Drop.
+var unsupported = []struct {
+	re        *regexp.Regexp
+	note      string
+	essential bool
+}{
+	{regexp.MustCompile(`(?s)<UI(\s[^>]*)?>.*?</UI>`), "installer UI", false},
+	{regexp.MustCompile(`<UIRef\s[^>]*/>`), "installer UI", false},
+	{regexp.MustCompile(`<WixVariable\s+Id="WixUI[^"]*"[^>]*/>`), "installer UI bitmaps", false},
+	{regexp.MustCompile(`<CustomActionRef\s+Id="WixBroadcastEnvironmentChange"[^>]*/>`), "environment change broadcast", false},
+	{regexp.MustCompile(`<util:ServiceConfig\s[^>]*/>`), "service recovery", true},
+	{regexp.MustCompile(`<util:PermissionEx\s[^>]*/>`), "data directory permissions", true},
+	{regexp.MustCompile(`<util:RemoveFolderEx\s[^>]*/>`), "data directory removal", true},
+	{regexp.MustCompile(`(?s)<SetProperty\s[^>]*Before="WixRemoveFoldersEx"[^>]*>.*?</SetProperty>`), "data directory removal", true},
+}
+
+var (
+	utilAction    = regexp.MustCompile(`<CustomAction\s[^>]*BinaryKey="WixCA"[^>]*/>`)
+	actionID      = regexp.MustCompile(`\sId="([^"]+)"`)
+	emptyFragment = regexp.MustCompile(`(?s)<Fragment>\s*</Fragment>`)
+	emptyWix      = regexp.MustCompile(`(?s)<Wix\s[^>]*>\s*</Wix>`)
+	comment       = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// Prepare removes the extension usages from the templates,
+// templates left without content are not compiled. The service recovery,
+// permissions, folder removals and custom actions fail unless Drop is set.
+func (t Wixl) Prepare(templates []string) ([]string, []string, error) {
+	var sources, notes []string
+	for _, tpl := range templates {
+		b, err := ioutil.ReadFile(tpl)
+		if err != nil {
+			return nil, nil, err
+		}
+		content := comment.ReplaceAllString(string(b), "")
+		noted, failed := make(map[string]bool), make(map[string]bool)
+		var essential []string
+		// essentialName names the construct in the error, when it must not be dropped
+		note := func(s, essentialName string) {
+			if !noted[s] {
+				noted[s] = true
+				notes = append(notes, fmt.Sprintf("%s: dropped %s", filepath.Base(tpl), s))
+			}
+			if essentialName != "" && !failed[essentialName] {
+				failed[essentialName] = true
+				essential = append(essential, essentialName)
+			}
+		}
+		for _, u := range unsupported {
+			if u.re.MatchString(content) {
+				content = u.re.ReplaceAllString(content, "")
+				essentialName := ""
+				if u.essential {
+					essentialName = u.note
+				}
+				note(u.note, essentialName)
+			}
+		}
+		for _, action := range utilAction.FindAllString(content, -1) {
+			m := actionID.FindStringSubmatch(action)
+			if m == nil {
+				continue
+			}
+			id := regexp.QuoteMeta(m[1])
+			content = strings.Replace(content, action, "", -1)
+			for _, re := range []string{
+				`<SetProperty\s[^>]*Before="` + id + `"[^>]*/>`,
+				`(?s)<Custom\s+Action="` + id + `"[^>]*?/>`,
+				`(?s)<Custom\s+Action="` + id + `"[^>]*[^/]>.*?</Custom>`,
+			} {
+				content = regexp.MustCompile(re).ReplaceAllString(content, "")
+			}
+			note(fmt.Sprintf("custom action %s", m[1]), "custom actions")
+		}
+		if len(essential) > 0 && !t.Drop {
+			return nil, nil, fmt.Errorf("wixl cannot build the %s of %s, use the wix backend or set --wixl-drop to drop them", strings.Join(essential, ", "), filepath.Base(tpl))
+		}
+		content = emptyFragment.ReplaceAllString(content, "")
+		if emptyWix.MatchString(content) {
+			continue
+		}
+		if err := ioutil.WriteFile(tpl, []byte(content), 0644); err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, tpl)
+	}
+	return sources, notes, nil
+}
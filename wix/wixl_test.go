@@ -0,0 +1,81 @@
+package wix
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const wixlTemplate = `<?xml version="1.0"?>
+<Wix xmlns="http://schemas.microsoft.com/wix/2006/wi" xmlns:util="http://schemas.microsoft.com/wix/UtilExtension">
+  <Product Id="*" Name="hello">
+    %s
+    <UIRef Id="WixUI_InstallDir"/>
+  </Product>
+</Wix>
+`
+
+func prepare(t *testing.T, toolchain Wixl, content string) (string, []string, error) {
+	dir, err := ioutil.TempDir("", "go-msi-wixl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tpl := filepath.Join(dir, "product.wxs")
+	if err := ioutil.WriteFile(tpl, []byte(strings.Replace(wixlTemplate, "%s", content, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources, notes, err := toolchain.Prepare([]string{tpl})
+	if err != nil {
+		return "", notes, err
+	}
+	if len(sources) != 1 {
+		t.Fatalf("sources = %v, want the template", sources)
+	}
+	b, err := ioutil.ReadFile(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), notes, nil
+}
+
+func TestWixlPrepare(t *testing.T) {
+	hook := `<CustomAction Id="CustomExec1" BinaryKey="WixCA" DllEntry="WixQuietExec" Execute="deferred"/>
+    <InstallExecuteSequence><Custom Action="CustomExec1" After="InstallFiles"><![CDATA[NOT Installed]]></Custom></InstallExecuteSequence>`
+	for _, c := range []struct {
+		name, content string
+		drop          bool
+		notes         []string
+		err           string
+	}{
+		{"installer UI", "", false, []string{"product.wxs: dropped installer UI"}, ""},
+		{"service recovery", `<util:ServiceConfig ServiceName="HelloSvc" FirstFailureActionType="restart"/>`, false, nil, "service recovery"},
+		{"permissions", `<util:PermissionEx User="Users" GenericRead="yes"/>`, false, nil, "data directory permissions"},
+		{"removal", `<util:RemoveFolderEx On="uninstall" Property="DATADIR"/>`, false, nil, "data directory removal"},
+		{"hook", hook, false, nil, "custom actions"},
+		{"dropped hook", hook, true, []string{"product.wxs: dropped installer UI", "product.wxs: dropped custom action CustomExec1"}, ""},
+	} {
+		content, notes, err := prepare(t, Wixl{Drop: c.drop}, c.content)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) || !strings.Contains(err.Error(), "--wixl-drop") {
+				t.Errorf("%s: error = %v, want a failure on the %s", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(notes, c.notes) {
+			t.Errorf("%s: notes = %q, want %q", c.name, notes, c.notes)
+		}
+		for _, dropped := range []string{"UIRef", "CustomExec1", "WixCA"} {
+			if strings.Contains(content, dropped) {
+				t.Errorf("%s: %s is left in the template:\n%s", c.name, dropped, content)
+			}
+		}
+	}
+}